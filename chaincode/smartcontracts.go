@@ -1,22 +1,526 @@
 package main
 
 import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
 	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"regexp"
+	"sort"
+	"strings"
 	"time"
+	"unicode/utf8"
 )
 
+// ContractName is the name the contract is registered under
+const ContractName = "SupplyChainSmartContract"
+
+// ContractVersion identifies the contract logic; bump it together with the chaincode package version
+const ContractVersion = "1.0"
+
+// Sentinel errors returned (wrapped) by the contract so callers can branch with errors.Is
+var (
+	ErrProductNotFound  = errors.New("product not found")
+	ErrProductExists    = errors.New("product already exists")
+	ErrPermissionDenied = errors.New("permission denied")
+	ErrVersionConflict  = errors.New("version conflict")
+	ErrImmutableField   = errors.New("immutable field")
+)
+
+// Chaincode event names emitted by the contract
+const (
+	ProductRegisteredEvent         = "ProductRegistered"
+	OwnershipTransferredEvent      = "OwnershipTransferred"
+	ProductDeletedEvent            = "ProductDeleted"
+	ProductsBatchRegisteredEvent   = "ProductsBatchRegistered"
+	ProductRecalledEvent           = "ProductRecalled"
+	BatchOwnershipTransferredEvent = "BatchOwnershipTransferred"
+	ProductSplitEvent              = "ProductSplit"
+	ProductFlaggedEvent            = "ProductFlagged"
+	OwnershipSwappedEvent          = "OwnershipSwapped"
+	ProductRestoredEvent           = "ProductRestored"
+	BulkStatusUpdatedEvent         = "BulkStatusUpdated"
+)
+
+// productIDPattern restricts product IDs to letters, digits and hyphens
+var productIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+// Key prefixes reserved for contract-internal records. Composite keys start with a null byte; the
+// named prefixes cover the internal record types should they ever be stored under plain keys.
+const (
+	compositeKeyPrefix   = "\x00"
+	categoryKeyPrefix    = "category~"
+	readingKeyPrefix     = "reading~"
+	statusLogKeyPrefix   = "statuslog~"
+	configKeyPrefix      = "config~"
+	bundleKeyPrefix      = "bundle~"
+	counterKeyPrefix     = "counter~"
+	idempotencyKeyPrefix = "idempotency~"
+)
+
+// reservedKeyPrefixes lists every prefix a product ID may not start with
+var reservedKeyPrefixes = []string{
+	compositeKeyPrefix,
+	categoryKeyPrefix,
+	readingKeyPrefix,
+	statusLogKeyPrefix,
+	configKeyPrefix,
+	bundleKeyPrefix,
+	counterKeyPrefix,
+	idempotencyKeyPrefix,
+}
+
+// documentHashPattern matches a hex-encoded SHA-256 digest
+var documentHashPattern = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// Maximum field lengths in characters, to keep records and blocks small
+const (
+	maxProductIDLength   = 64
+	maxNameLength        = 256
+	maxOwnerLength       = 256
+	maxDescriptionLength = 4096
+	maxCategoryLength    = 128
+)
+
+// validCategories lists the canonical product categories
+var validCategories = []string{
+	"Apparel",
+	"Automotive",
+	"Chemicals",
+	"Electronics",
+	"Food",
+	"Furniture",
+	"Pharmaceuticals",
+	"Other",
+}
+
+// categoryIndex is the composite key object type used to look up products by category
+const categoryIndex = "category~id"
+
+// privateDetailsTransientKey is the transient map key clients may use to pass private details off-chain
+const privateDetailsTransientKey = "product_details"
+
+// sensorReadingIndex is the composite key object type under which sensor readings are stored
+const sensorReadingIndex = "reading~productid~timestamp"
+
+// statusLogIndex is the composite key object type under which status changes are logged
+const statusLogIndex = "statuslog~productid~txid"
+
+// defaultMaxTemperature is the cold-chain limit in degrees Celsius used when a product has no threshold of its own
+const defaultMaxTemperature = 8.0
+
+// adminMSPID is the organization allowed to manage contract-wide configuration
+const adminMSPID = "Org1MSP"
+
+// configIndex is the composite key object type for contract configuration records. Composite keys
+// are skipped by plain range scans, so configuration never shows up as a product.
+const configIndex = "config~name"
+
+// approvedOwnersConfig is the configuration record holding the owner allow-list
+const approvedOwnersConfig = "approvedOwners"
+
+// allowedStatusesConfig is the configuration record holding the deployment's status list
+const allowedStatusesConfig = "allowedStatuses"
+
+// defaultStatusConfig is the configuration record holding the status new products start in
+const defaultStatusConfig = "defaultStatus"
+
+// bundleIndex is the composite key type for bundles; composite keys are skipped by plain range scans,
+// so bundles never show up as products
+const bundleIndex = "bundle~id"
+
+// idempotencyIndex is the composite key type mapping client idempotency keys to the product they created.
+// Entries are kept indefinitely; deployments that need expiry can prune them administratively.
+const idempotencyIndex = "idempotency~key"
+
+// productIDCounterConfig is the configuration record holding the last auto-assigned product number
+const productIDCounterConfig = "productIDCounter"
+
+// autoProductIDFormat formats auto-assigned product IDs, e.g. prod-000123
+const autoProductIDFormat = "prod-%06d"
+
+// unassignedOwnerKey groups products with an empty owner in per-owner counts
+const unassignedOwnerKey = "(no owner)"
+
+// ownerAttribute is the optional X.509 attribute that ties a client certificate to a single owner name
+const ownerAttribute = "owner"
+
+// adminAttribute is the X.509 attribute, set to "true", that marks an admin organization client as a contract
+// admin. Issue it with e.g. fabric-ca-client register --id.attrs 'supplychain.admin=true:ecert'.
+const adminAttribute = "supplychain.admin"
+
+// Product lifecycle statuses
+const (
+	StatusManufactured = "Manufactured"
+	StatusInTransit    = "InTransit"
+	StatusDelivered    = "Delivered"
+	StatusSold         = "Sold"
+	StatusRecalled     = "Recalled"
+	StatusDepleted     = "Depleted"
+	StatusDisposed     = "Disposed"
+	StatusSplit        = "Split"
+	StatusConsumed     = "ConsumedInAssembly"
+	StatusExpired      = "Expired"
+	StatusHeld         = "Held"
+)
+
+// defaultStatuses is the status list used until an admin configures one
+var defaultStatuses = []string{
+	StatusManufactured,
+	StatusInTransit,
+	StatusHeld,
+	StatusDelivered,
+	StatusSold,
+	StatusRecalled,
+	StatusDepleted,
+	StatusDisposed,
+	StatusSplit,
+	StatusConsumed,
+	StatusExpired,
+}
+
+// maxLineageDepth caps how many generations GetProductLineage follows in each direction
+const maxLineageDepth = 10
+
+// Lineage edge relations: a split links a parent to a child, an assembly links a component to the assembled product
+const (
+	LineageSplit    = "split"
+	LineageAssembly = "assembly"
+)
+
+// defaultQuantity is used when a product is registered without a quantity
+const defaultQuantity = 1
+
+// statusTransitions lists the statuses a product may move to from each status
+var statusTransitions = map[string][]string{
+	StatusManufactured: {StatusInTransit, StatusRecalled, StatusDepleted, StatusSplit, StatusConsumed, StatusExpired},
+	StatusInTransit:    {StatusDelivered, StatusHeld, StatusRecalled, StatusDepleted, StatusSplit, StatusConsumed, StatusExpired},
+	StatusHeld:         {StatusInTransit, StatusRecalled, StatusDepleted, StatusExpired},
+	StatusDelivered:    {StatusInTransit, StatusSold, StatusRecalled, StatusDepleted, StatusSplit, StatusConsumed, StatusExpired},
+	StatusSold:         {StatusRecalled, StatusDepleted, StatusExpired},
+	StatusRecalled:     {StatusDisposed},
+	StatusDepleted:     {StatusRecalled},
+	StatusExpired:      {StatusRecalled, StatusDepleted, StatusDisposed},
+	StatusDisposed:     {},
+	StatusSplit:        {},
+	StatusConsumed:     {},
+}
+
 // ProductEntity represents the structure of a product in the supply chain
 type ProductEntity struct {
 	ProductID   string `json:"product_id"`
 	ProductName string `json:"product_name"`
 	ProductStatus string `json:"product_status"`
 	CurrentOwner string `json:"current_owner"`
+	CurrentOwnerOrg string `json:"current_owner_org"`
+	PendingOwner string `json:"pending_owner,omitempty" metadata:",optional"`
+	PendingOwnerOrg string `json:"pending_owner_org,omitempty" metadata:",optional"`
+	PendingSwapWith string `json:"pending_swap_with,omitempty" metadata:",optional"`
+	CurrentLocation string `json:"current_location,omitempty" metadata:",optional"`
+	Quantity int `json:"quantity"`
+	RecallReason string `json:"recall_reason,omitempty" metadata:",optional"`
+	RecalledDate string `json:"recalled_date,omitempty" metadata:",optional"`
+	Version int `json:"version"`
+	ColdChain *ColdChainSettings `json:"cold_chain,omitempty" metadata:",optional"`
+	ColdChainBreached bool `json:"cold_chain_breached"`
+	Certifications []string `json:"certifications,omitempty" metadata:",optional"`
+	ExpiryDate string `json:"expiry_date,omitempty" metadata:",optional"`
+	Archived bool `json:"archived"`
+	CreatedBy string `json:"created_by"`
+	LastModifiedBy string `json:"last_modified_by"`
+	Metadata map[string]string `json:"metadata,omitempty" metadata:",optional"`
+	ParentID string `json:"parent_id,omitempty" metadata:",optional"`
+	ComponentIDs []string `json:"component_ids,omitempty" metadata:",optional"`
+	DocumentHashes map[string]string `json:"document_hashes,omitempty" metadata:",optional"`
+	Manufacturer string `json:"manufacturer,omitempty" metadata:",optional"`
+	LastTxID string `json:"last_tx_id,omitempty" metadata:",optional"`
+	Flagged bool `json:"flagged"`
+	FlagReason string `json:"flag_reason,omitempty" metadata:",optional"`
+	ListPrice float64 `json:"list_price,omitempty" metadata:",optional"`
 	CreatedDate  string `json:"created_date"`
 	UpdatedDate  string `json:"updated_date"`
-	ProductCategory string `json:"product_category"`
+	ProductCategory string `json:"product_category,omitempty" metadata:",optional"`
+	ProductDescription string `json:"product_description,omitempty" metadata:",optional"`
+}
+
+// ProductPrivateDetails holds commercially sensitive product data kept in a private data collection
+type ProductPrivateDetails struct {
+	ProductID    string  `json:"product_id"`
+	Price        float64 `json:"price"`
+	SupplierCost float64 `json:"supplier_cost"`
+}
+
+// StatusChange is one entry in a product's status log
+type StatusChange struct {
+	Status    string `json:"status"`
+	Timestamp string `json:"timestamp"`
+	ChangedBy string `json:"changed_by"`
+}
+
+// ColdChainSettings holds the temperature limits for a cold-chain product
+type ColdChainSettings struct {
+	MaxTemperature float64 `json:"max_temperature"`
+}
+
+// SensorReading is a single IoT measurement recorded against a product
+type SensorReading struct {
+	ProductID   string  `json:"product_id"`
+	Timestamp   string  `json:"timestamp"`
+	Temperature float64 `json:"temperature"`
+	Humidity    float64 `json:"humidity"`
+}
+
+// ColdChainBreach lists the readings above a breached product's threshold. MaxTemperature is the highest
+// reading recorded for the product and WorstBreachTimestamp is when it was taken.
+type ColdChainBreach struct {
+	Product              *ProductEntity   `json:"product"`
+	MaxTemperature       float64          `json:"max_temperature"`
+	Threshold            float64          `json:"threshold"`
+	WorstBreachTimestamp string           `json:"worst_breach_timestamp"`
+	BreachingReadings    []*SensorReading `json:"breaching_readings"`
+}
+
+// ProductInput holds the caller-supplied fields used to register a product
+type ProductInput struct {
+	ProductID          string `json:"product_id"`
+	ProductName        string `json:"product_name"`
+	CurrentOwner       string `json:"current_owner"`
 	ProductDescription string `json:"product_description"`
+	ProductCategory    string `json:"product_category"`
+	Quantity           int    `json:"quantity"`
+	ExpiryDate         string `json:"expiry_date"`
+
+	// ParentID, ComponentIDs and Manufacturer are set by the contract when a product is derived
+	// from others; clients cannot supply them
+	ParentID     string   `json:"-"`
+	ComponentIDs []string `json:"-"`
+	Manufacturer string   `json:"-"`
+}
+
+// LedgerSeedResult reports which seed products were created and which were skipped because they already existed
+type LedgerSeedResult struct {
+	Created []string `json:"created"`
+	Skipped []string `json:"skipped"`
+}
+
+// ProductHistoryEntry represents a single state change of a product on the ledger
+type ProductHistoryEntry struct {
+	TxID      string         `json:"tx_id"`
+	Timestamp string         `json:"timestamp"`
+	Product   *ProductEntity `json:"product,omitempty" metadata:",optional"`
+	IsDeleted bool           `json:"is_deleted"`
+}
+
+// PaginatedProductsResult holds a single page of products and the bookmark for the next page
+type PaginatedProductsResult struct {
+	Products            []*ProductEntity `json:"products"`
+	Bookmark            string           `json:"bookmark"`
+	FetchedRecordsCount int32            `json:"fetched_records_count"`
+}
+
+// OwnershipTransferredPayload is the event payload emitted when a product changes hands
+type OwnershipTransferredPayload struct {
+	ProductID     string `json:"product_id"`
+	PreviousOwner string `json:"previous_owner"`
+	NewOwner      string `json:"new_owner"`
+	NewOwnerOrg   string `json:"new_owner_org"`
+	Timestamp     string `json:"timestamp"`
+}
+
+// OwnershipSwappedPayload is the event payload emitted when two products exchange owners
+type OwnershipSwappedPayload struct {
+	ProductIDA string `json:"product_id_a"`
+	ProductIDB string `json:"product_id_b"`
+	Timestamp  string `json:"timestamp"`
+}
+
+// BatchOwnershipTransferredPayload is the event payload emitted when a shipment of products changes hands
+type BatchOwnershipTransferredPayload struct {
+	ProductIDs  []string `json:"product_ids"`
+	NewOwner    string   `json:"new_owner"`
+	NewOwnerOrg string   `json:"new_owner_org"`
+	Timestamp   string   `json:"timestamp"`
+}
+
+// BulkStatusUpdatedPayload is the event payload emitted when products are moved between statuses in bulk
+type BulkStatusUpdatedPayload struct {
+	FromStatus string   `json:"from_status"`
+	ToStatus   string   `json:"to_status"`
+	ProductIDs []string `json:"product_ids"`
+	Timestamp  string   `json:"timestamp"`
+}
+
+// ProductSplitPayload is the event payload emitted when a product is split into child products
+type ProductSplitPayload struct {
+	ParentID string   `json:"parent_id"`
+	ChildIDs []string `json:"child_ids"`
+}
+
+// ProductDeletedPayload is the event payload emitted when a product is removed from the ledger
+type ProductDeletedPayload struct {
+	ProductID string `json:"product_id"`
+}
+
+// ProductsBatchRegisteredPayload is the event payload emitted when a batch of products is registered
+type ProductsBatchRegisteredPayload struct {
+	ProductIDs []string `json:"product_ids"`
+}
+
+// ProductLookupResult holds the products found for a list of IDs and the IDs that did not exist
+type ProductLookupResult struct {
+	Products []*ProductEntity `json:"products"`
+	NotFound []string         `json:"not_found"`
+}
+
+// OwnershipRecord is one link in a product's chain of custody
+type OwnershipRecord struct {
+	Owner         string `json:"owner"`
+	FromTimestamp string `json:"from_timestamp"`
+}
+
+// OwnershipDuration is how long one owner held a product. An owner who held the product more than
+// once gets one entry per holding period.
+type OwnershipDuration struct {
+	Owner           string `json:"owner"`
+	DurationSeconds int64  `json:"duration_seconds"`
+}
+
+// TransferReceipt describes the most recent ownership transfer of a product. TxID identifies the
+// committed transaction, so the receipt can be checked against the ledger by any peer.
+type TransferReceipt struct {
+	ProductID     string `json:"product_id"`
+	Transferred   bool   `json:"transferred"`
+	PreviousOwner string `json:"previous_owner"`
+	NewOwner      string `json:"new_owner"`
+	Timestamp     string `json:"timestamp"`
+	TxID          string `json:"tx_id"`
+}
+
+// BundleEntity is a kit of existing products that are sold and transferred together
+type BundleEntity struct {
+	BundleID    string   `json:"bundle_id"`
+	BundleName  string   `json:"bundle_name"`
+	MemberIDs   []string `json:"member_ids"`
+	CreatedDate string   `json:"created_date"`
+	CreatedBy   string   `json:"created_by"`
+}
+
+// ProductProof pairs a product with the SHA-256 hash of its canonical JSON, which clients can store
+// and later recompute to confirm the record has not changed
+type ProductProof struct {
+	Product *ProductEntity `json:"product"`
+	Hash    string         `json:"hash"`
+}
+
+// AlertProduct is a product that needs operational attention, with the reasons it was selected
+type AlertProduct struct {
+	Product      *ProductEntity `json:"product"`
+	AlertReasons []string       `json:"alert_reasons"`
+}
+
+// Reasons reported by GetAlertProducts
+const (
+	AlertRecalled        = "recalled"
+	AlertFlagged         = "flagged"
+	AlertExpired         = "expired"
+	AlertColdChainBreach = "cold_chain_breach"
+)
+
+// LineageEdge links a source product to a product derived from it
+type LineageEdge struct {
+	From     string `json:"from"`
+	To       string `json:"to"`
+	Relation string `json:"relation"`
+}
+
+// ProductLineage is the family tree of a product across splits and assemblies
+type ProductLineage struct {
+	RootID    string           `json:"root_id"`
+	Nodes     []*ProductEntity `json:"nodes"`
+	Edges     []*LineageEdge   `json:"edges"`
+	Truncated bool             `json:"truncated"`
+}
+
+// ProductValidationResult lists every problem found in a product payload by ValidateProduct
+type ProductValidationResult struct {
+	Valid  bool     `json:"valid"`
+	Errors []string `json:"errors"`
+}
+
+// TransferRecord is a single ownership change found in a product's history
+type TransferRecord struct {
+	ProductID string `json:"product_id"`
+	FromOwner string `json:"from_owner"`
+	ToOwner   string `json:"to_owner"`
+	Timestamp string `json:"timestamp"`
+	TxID      string `json:"tx_id"`
+}
+
+// ContractInfo describes the deployed contract and the values it accepts
+type ContractInfo struct {
+	Name       string   `json:"name"`
+	Version    string   `json:"version"`
+	Statuses   []string `json:"statuses"`
+	Categories []string `json:"categories"`
+}
+
+// ProvenanceReport combines everything known about a single product for compliance reporting
+type ProvenanceReport struct {
+	Product        *ProductEntity     `json:"product"`
+	OwnershipChain []*OwnershipRecord `json:"ownership_chain"`
+	StatusHistory  []*StatusChange    `json:"status_history"`
+	SensorReadings []*SensorReading   `json:"sensor_readings"`
+}
+
+// LedgerStatistics summarizes the products on the ledger by status and category
+type LedgerStatistics struct {
+	TotalCount      int            `json:"total_count"`
+	CountByStatus   map[string]int `json:"count_by_status"`
+	CountByCategory map[string]int `json:"count_by_category"`
+}
+
+// StatusCount is one stage of the status funnel
+type StatusCount struct {
+	Status string `json:"status"`
+	Count  int    `json:"count"`
+}
+
+// IntegrityIssue describes why a stored product fails the current validation rules
+type IntegrityIssue struct {
+	ProductID string `json:"product_id"`
+	Problem   string `json:"problem"`
+}
+
+// IntegrityReport lists the stored products that fail the current validation rules
+type IntegrityReport struct {
+	CheckedCount int               `json:"checked_count"`
+	Issues       []*IntegrityIssue `json:"issues"`
+}
+
+// ProductHistoryPage holds a slice of a product's history and whether later entries remain
+type ProductHistoryPage struct {
+	Entries []*ProductHistoryEntry `json:"entries"`
+	HasMore bool                   `json:"has_more"`
+}
+
+// ProductWithHistory holds a product's current state and its most recent history entries, oldest first.
+// Truncated reports whether older entries were left out.
+type ProductWithHistory struct {
+	Product   *ProductEntity         `json:"product"`
+	History   []*ProductHistoryEntry `json:"history"`
+	Truncated bool                   `json:"truncated"`
+}
+
+// AgedProductsResult holds the products created before a retention cutoff and the IDs of records
+// whose CreatedDate could not be parsed
+type AgedProductsResult struct {
+	Products       []*ProductEntity `json:"products"`
+	UnparseableIDs []string         `json:"unparseable_ids"`
 }
 
 // SupplyChainSmartContract defines the smart contract
@@ -26,154 +530,4070 @@ type SupplyChainSmartContract struct {
 
 // fetchTransactionTimestamp retrieves the current transaction timestamp
 func (s *SupplyChainSmartContract) fetchTransactionTimestamp(ctx contractapi.TransactionContextInterface) (string, error) {
-	timestamp, err := ctx.GetStub().GetTxTimestamp()
+	txTime, err := s.fetchTransactionTime(ctx)
 	if err != nil {
-		return "", fmt.Errorf("unable to retrieve transaction timestamp: %v", err)
+		return "", err
 	}
-	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).Format(time.RFC3339), nil
+	return txTime.Format(time.RFC3339), nil
 }
 
-// InitializeLedger adds initial data to the ledger
-func (s *SupplyChainSmartContract) InitializeLedger(ctx contractapi.TransactionContextInterface) error {
-	timeNow, err := s.fetchTransactionTimestamp(ctx)
+// fetchTransactionTime retrieves the current transaction timestamp as a time.Time
+func (s *SupplyChainSmartContract) fetchTransactionTime(ctx contractapi.TransactionContextInterface) (time.Time, error) {
+	timestamp, err := ctx.GetStub().GetTxTimestamp()
 	if err != nil {
-		return err
+		return time.Time{}, fmt.Errorf("unable to retrieve transaction timestamp: %v", err)
 	}
+	// UTC keeps stored timestamps identical across endorsing peers in different time zones
+	return time.Unix(timestamp.Seconds, int64(timestamp.Nanos)).UTC(), nil
+}
 
-	initialProducts := []ProductEntity{
-		{ProductID: "prod1", ProductName: "Gaming Laptop", ProductStatus: "Manufactured", CurrentOwner: "TechCorp", CreatedDate: timeNow, UpdatedDate: timeNow, ProductDescription: "A high-performance gaming laptop", ProductCategory: "Electronics"},
-		{ProductID: "prod2", ProductName: "5G Smartphone", ProductStatus: "Manufactured", CurrentOwner: "MobileCo", CreatedDate: timeNow, UpdatedDate: timeNow, ProductDescription: "Latest 5G-enabled smartphone", ProductCategory: "Electronics"},
+// InitializeLedger adds demo data to the ledger. Products that already exist are left untouched,
+// so running it again is harmless.
+func (s *SupplyChainSmartContract) InitializeLedger(ctx contractapi.TransactionContextInterface) (*LedgerSeedResult, error) {
+	initialProducts := []ProductInput{
+		{ProductID: "prod1", ProductName: "Gaming Laptop", CurrentOwner: "TechCorp", ProductDescription: "A high-performance gaming laptop", ProductCategory: "Electronics", Quantity: 1},
+		{ProductID: "prod2", ProductName: "5G Smartphone", CurrentOwner: "MobileCo", ProductDescription: "Latest 5G-enabled smartphone", ProductCategory: "Electronics", Quantity: 1},
 	}
 
-	for _, product := range initialProducts {
-		if err := s.saveProduct(ctx, &product); err != nil {
-			return err
+	return s.seedProducts(ctx, initialProducts)
+}
+
+// InitializeLedgerFromJSON seeds the ledger with a network's own catalog given as a JSON array of products.
+// Each product is validated like a registration; IDs that already exist are skipped.
+func (s *SupplyChainSmartContract) InitializeLedgerFromJSON(ctx contractapi.TransactionContextInterface, productsJSON string) (*LedgerSeedResult, error) {
+	var inputs []ProductInput
+	if err := json.Unmarshal([]byte(productsJSON), &inputs); err != nil {
+		return nil, fmt.Errorf("failed to parse seed products: %v", err)
+	}
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("seed products must not be empty")
+	}
+
+	return s.seedProducts(ctx, inputs)
+}
+
+// seedProducts registers each product that does not exist yet and reports which IDs were created or skipped
+func (s *SupplyChainSmartContract) seedProducts(ctx contractapi.TransactionContextInterface, inputs []ProductInput) (*LedgerSeedResult, error) {
+	result := &LedgerSeedResult{Created: []string{}, Skipped: []string{}}
+
+	// Writes are not visible to GetState within the same transaction, so catch repeated IDs here
+	seen := make(map[string]bool, len(inputs))
+	for i, input := range inputs {
+		if seen[input.ProductID] {
+			result.Skipped = append(result.Skipped, input.ProductID)
+			continue
 		}
+		seen[input.ProductID] = true
+
+		if _, err := s.createProduct(ctx, input); err != nil {
+			if errors.Is(err, ErrProductExists) {
+				result.Skipped = append(result.Skipped, input.ProductID)
+				continue
+			}
+			return nil, fmt.Errorf("seed product %d: %v", i, err)
+		}
+		result.Created = append(result.Created, input.ProductID)
 	}
 
-	return nil
+	return result, nil
 }
 
-// RegisterProduct adds a new product to the ledger
-func (s *SupplyChainSmartContract) RegisterProduct(ctx contractapi.TransactionContextInterface, id, name, owner, description, category string) error {
-	exists, err := s.CheckProductExistence(ctx, id)
+// RegisterProduct adds a new product to the ledger; a quantity of zero defaults to a single unit
+// and an empty expiry date means the product never expires
+func (s *SupplyChainSmartContract) RegisterProduct(ctx contractapi.TransactionContextInterface, id, name, owner, description, category string, quantity int, expiryDate string) error {
+	newProduct, err := s.createProduct(ctx, ProductInput{
+		ProductID: id, ProductName: name, CurrentOwner: owner, ProductDescription: description, ProductCategory: category, Quantity: quantity, ExpiryDate: expiryDate,
+	})
 	if err != nil {
 		return err
 	}
-	if exists {
-		return fmt.Errorf("product with ID %s already exists", id)
-	}
 
-	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	return s.emitEvent(ctx, ProductRegisteredEvent, newProduct)
+}
+
+// RegisterProductV2 behaves like RegisterProduct but returns the stored product, including its
+// server-assigned timestamps, so clients do not need a follow-up RetrieveProduct
+func (s *SupplyChainSmartContract) RegisterProductV2(ctx contractapi.TransactionContextInterface, id, name, owner, description, category string, quantity int, expiryDate string) (*ProductEntity, error) {
+	newProduct, err := s.createProduct(ctx, ProductInput{
+		ProductID: id, ProductName: name, CurrentOwner: owner, ProductDescription: description, ProductCategory: category, Quantity: quantity, ExpiryDate: expiryDate,
+	})
 	if err != nil {
-		return err
+		return nil, err
 	}
 
-	newProduct := ProductEntity{
-		ProductID: id, ProductName: name, ProductStatus: "Manufactured", CurrentOwner: owner, CreatedDate: timeNow, UpdatedDate: timeNow, ProductDescription: description, ProductCategory: category,
+	if err := s.emitEvent(ctx, ProductRegisteredEvent, newProduct); err != nil {
+		return nil, err
 	}
 
-	return s.saveProduct(ctx, &newProduct)
+	return newProduct, nil
 }
 
-// ModifyProduct updates existing product details
-func (s *SupplyChainSmartContract) ModifyProduct(ctx contractapi.TransactionContextInterface, id, status, owner, description, category string) error {
-	productBytes, err := ctx.GetStub().GetState(id)
+// RegisterProductAutoID registers a product under the next sequential ID (prod-000001, prod-000002, ...)
+// and returns the assigned ID. Every call reads and writes the same counter key, so concurrent
+// registrations fail with MVCC read conflicts at commit time; clients should retry those.
+func (s *SupplyChainSmartContract) RegisterProductAutoID(ctx contractapi.TransactionContextInterface, name, owner, description, category string) (string, error) {
+	var counter int
+	if _, err := s.getConfig(ctx, productIDCounterConfig, &counter); err != nil {
+		return "", err
+	}
+
+	// Skip numbers already taken by products registered with an explicit ID
+	var id string
+	for {
+		counter++
+		id = fmt.Sprintf(autoProductIDFormat, counter)
+		exists, err := s.CheckProductExistence(ctx, id)
+		if err != nil {
+			return "", err
+		}
+		if !exists {
+			break
+		}
+	}
+
+	newProduct, err := s.createProduct(ctx, ProductInput{
+		ProductID: id, ProductName: name, CurrentOwner: owner, ProductDescription: description, ProductCategory: category,
+	})
 	if err != nil {
-		return fmt.Errorf("error retrieving product: %v", err)
+		return "", err
 	}
-	if productBytes == nil {
-		return fmt.Errorf("product with ID %s does not exist", id)
+	if err := s.putConfig(ctx, productIDCounterConfig, counter); err != nil {
+		return "", err
 	}
 
-	var product ProductEntity
-	json.Unmarshal(productBytes, &product)
+	if err := s.emitEvent(ctx, ProductRegisteredEvent, newProduct); err != nil {
+		return "", err
+	}
 
-	if status != "" {
-		product.ProductStatus = status
+	return id, nil
+}
+
+// RegisterProductIdempotent registers a product like RegisterProductV2, but remembers the client's
+// idempotency key. A retry with the same key returns the product created the first time instead of
+// registering another one. If that product has since been deleted, the key is treated as unused and
+// remapped to the newly registered product. An empty key disables deduplication.
+func (s *SupplyChainSmartContract) RegisterProductIdempotent(ctx contractapi.TransactionContextInterface, idempotencyKey, id, name, owner, description, category string, quantity int, expiryDate string) (*ProductEntity, error) {
+	if idempotencyKey == "" {
+		return s.RegisterProductV2(ctx, id, name, owner, description, category, quantity, expiryDate)
 	}
-	if owner != "" {
-		product.CurrentOwner = owner
+
+	key, err := ctx.GetStub().CreateCompositeKey(idempotencyIndex, []string{idempotencyKey})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create idempotency key: %v", err)
 	}
-	if description != "" {
-		product.ProductDescription = description
+	existingID, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading idempotency key: %v", err)
 	}
-	if category != "" {
-		product.ProductCategory = category
+	if existingID != nil {
+		existing, err := s.RetrieveProduct(ctx, string(existingID))
+		if !errors.Is(err, ErrProductNotFound) {
+			return existing, err
+		}
 	}
 
-	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	newProduct, err := s.RegisterProductV2(ctx, id, name, owner, description, category, quantity, expiryDate)
 	if err != nil {
-		return err
+		return nil, err
+	}
+	if err := ctx.GetStub().PutState(key, []byte(newProduct.ProductID)); err != nil {
+		return nil, fmt.Errorf("failed to store idempotency key: %v", err)
 	}
 
-	return s.saveProduct(ctx, &product)
+	return newProduct, nil
 }
 
-// TransferOwnership assigns a new owner to the product
-func (s *SupplyChainSmartContract) TransferOwnership(ctx contractapi.TransactionContextInterface, id, newOwner string) error {
-	return s.ModifyProduct(ctx, id, "", newOwner, "", "")
+// RegisterProductsBatch registers a JSON array of products in a single transaction.
+// The batch is all-or-nothing: if any product is invalid or already exists, nothing is written.
+func (s *SupplyChainSmartContract) RegisterProductsBatch(ctx contractapi.TransactionContextInterface, productsJSON string) (int, error) {
+	var inputs []ProductInput
+	if err := json.Unmarshal([]byte(productsJSON), &inputs); err != nil {
+		return 0, fmt.Errorf("failed to parse products batch: %v", err)
+	}
+	if len(inputs) == 0 {
+		return 0, fmt.Errorf("products batch must not be empty")
+	}
+
+	// Writes are not visible to GetState within the same transaction, so catch repeated IDs here
+	seen := make(map[string]bool, len(inputs))
+	ids := make([]string, 0, len(inputs))
+	for i, input := range inputs {
+		if seen[input.ProductID] {
+			return 0, fmt.Errorf("product %d in batch: duplicate product ID %s", i, input.ProductID)
+		}
+		seen[input.ProductID] = true
+
+		if _, err := s.createProduct(ctx, input); err != nil {
+			return 0, fmt.Errorf("product %d in batch: %v", i, err)
+		}
+		ids = append(ids, input.ProductID)
+	}
+
+	if err := s.emitEvent(ctx, ProductsBatchRegisteredEvent, ProductsBatchRegisteredPayload{ProductIDs: ids}); err != nil {
+		return 0, err
+	}
+
+	return len(ids), nil
 }
 
-// RetrieveProduct fetches product details based on the product ID
-func (s *SupplyChainSmartContract) RetrieveProduct(ctx contractapi.TransactionContextInterface, id string) (*ProductEntity, error) {
-	productBytes, err := ctx.GetStub().GetState(id)
+// SplitProduct repackages a product into child products that reference it through ParentID.
+// Children default to the parent's owner and category; their combined quantity may not exceed
+// the parent's, which keeps any remainder and moves to the Split status.
+func (s *SupplyChainSmartContract) SplitProduct(ctx contractapi.TransactionContextInterface, parentID, childrenJSON string) error {
+	parent, err := s.RetrieveProduct(ctx, parentID)
 	if err != nil {
-		return nil, fmt.Errorf("error fetching product details: %v", err)
+		return err
 	}
-	if productBytes == nil {
-		return nil, fmt.Errorf("product with ID %s not found", id)
+	if err := s.assertOwnerOrg(ctx, parent); err != nil {
+		return err
+	}
+	if err := validateStatusTransition(parent.ProductStatus, StatusSplit); err != nil {
+		return err
 	}
 
-	var product ProductEntity
-	if err := json.Unmarshal(productBytes, &product); err != nil {
-		return nil, err
+	var children []ProductInput
+	if err := json.Unmarshal([]byte(childrenJSON), &children); err != nil {
+		return fmt.Errorf("failed to parse child products: %v", err)
+	}
+	if len(children) == 0 {
+		return fmt.Errorf("at least one child product is required to split %s", parentID)
 	}
 
-	return &product, nil
-}
+	totalQuantity := 0
+	for i := range children {
+		if children[i].Quantity == 0 {
+			children[i].Quantity = defaultQuantity
+		}
+		totalQuantity += children[i].Quantity
+	}
+	if totalQuantity > parent.Quantity {
+		return fmt.Errorf("child quantities total %d but product %s only has %d", totalQuantity, parentID, parent.Quantity)
+	}
 
-// saveProduct is a utility function to add or update a product in the ledger
-func (s *SupplyChainSmartContract) saveProduct(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
-	productBytes, err := json.Marshal(product)
+	seen := make(map[string]bool, len(children))
+	childIDs := make([]string, 0, len(children))
+	for i, child := range children {
+		if seen[child.ProductID] {
+			return fmt.Errorf("child %d: duplicate product ID %s", i, child.ProductID)
+		}
+		seen[child.ProductID] = true
+
+		if child.CurrentOwner == "" {
+			child.CurrentOwner = parent.CurrentOwner
+		}
+		if child.ProductCategory == "" {
+			child.ProductCategory = parent.ProductCategory
+		}
+		child.ParentID = parentID
+		child.Manufacturer = parent.Manufacturer
+
+		if _, err := s.createProduct(ctx, child); err != nil {
+			return fmt.Errorf("child %d: %v", i, err)
+		}
+		childIDs = append(childIDs, child.ProductID)
+	}
+
+	parent.ProductStatus = StatusSplit
+	parent.Quantity -= totalQuantity
+	parent.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
 	if err != nil {
 		return err
 	}
-	return ctx.GetStub().PutState(product.ProductID, productBytes)
+	if err := s.saveProduct(ctx, parent); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, ProductSplitEvent, ProductSplitPayload{ParentID: parentID, ChildIDs: childIDs})
 }
 
-// CheckProductExistence verifies if a product exists in the ledger
-func (s *SupplyChainSmartContract) CheckProductExistence(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
-	productBytes, err := ctx.GetStub().GetState(id)
-	if err != nil {
-		return false, fmt.Errorf("error checking product existence: %v", err)
+// MergeProducts assembles several component products into a new product that lists them in ComponentIDs.
+// Each component moves to ConsumedInAssembly; components that are already consumed, recalled or
+// otherwise unable to move to that status are rejected.
+func (s *SupplyChainSmartContract) MergeProducts(ctx contractapi.TransactionContextInterface, componentIDsJSON, newProductJSON string) error {
+	var componentIDs []string
+	if err := json.Unmarshal([]byte(componentIDsJSON), &componentIDs); err != nil {
+		return fmt.Errorf("failed to parse component IDs: %v", err)
+	}
+	if len(componentIDs) == 0 {
+		return fmt.Errorf("at least one component is required for an assembly")
 	}
-	return productBytes != nil, nil
-}
 
-// ListAllProducts retrieves all products from the ledger
-func (s *SupplyChainSmartContract) ListAllProducts(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
-	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	var input ProductInput
+	if err := json.Unmarshal([]byte(newProductJSON), &input); err != nil {
+		return fmt.Errorf("failed to parse assembled product: %v", err)
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer resultsIterator.Close()
 
-	var allProducts []*ProductEntity
-	for resultsIterator.HasNext() {
-		queryResponse, err := resultsIterator.Next()
+	seen := make(map[string]bool, len(componentIDs))
+	for _, id := range componentIDs {
+		if seen[id] {
+			return fmt.Errorf("duplicate component ID %s", id)
+		}
+		seen[id] = true
+
+		component, err := s.RetrieveProduct(ctx, id)
 		if err != nil {
-			return nil, err
+			return err
+		}
+		if err := s.assertOwnerOrg(ctx, component); err != nil {
+			return err
+		}
+		if component.ProductStatus == StatusConsumed || component.ProductStatus == StatusRecalled {
+			return fmt.Errorf("component %s is %s and cannot be assembled", id, component.ProductStatus)
+		}
+		if err := validateStatusTransition(component.ProductStatus, StatusConsumed); err != nil {
+			return fmt.Errorf("component %s: %v", id, err)
 		}
 
-		var product ProductEntity
-		if err := json.Unmarshal(queryResponse.Value, &product); err != nil {
-			return nil, err
+		component.ProductStatus = StatusConsumed
+		component.UpdatedDate = timeNow
+		if err := s.saveProduct(ctx, component); err != nil {
+			return err
 		}
-		allProducts = append(allProducts, &product)
 	}
 
-	return allProducts, nil
+	input.ComponentIDs = componentIDs
+	assembly, err := s.createProduct(ctx, input)
+	if err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, ProductRegisteredEvent, assembly)
+}
+
+// createProduct validates the input and writes a new product owned by the caller's organization
+func (s *SupplyChainSmartContract) createProduct(ctx contractapi.TransactionContextInterface, input ProductInput) (*ProductEntity, error) {
+	problems, err := s.validateNewProduct(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	if len(problems) > 0 {
+		return nil, problems[0]
+	}
+
+	if input.ProductCategory != "" {
+		// Already validated above
+		input.ProductCategory, _ = normalizeCategory(input.ProductCategory)
+	}
+	if input.Quantity == 0 {
+		input.Quantity = defaultQuantity
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	ownerOrg, err := s.getClientOrgID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	creator, err := s.getClientID(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	initialStatus, err := s.readDefaultStatus(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// The registering owner is the manufacturer unless the product was derived from another one
+	manufacturer := input.Manufacturer
+	if manufacturer == "" {
+		manufacturer = input.CurrentOwner
+	}
+
+	newProduct := ProductEntity{
+		ProductID: input.ProductID, ProductName: input.ProductName, ProductStatus: initialStatus, CurrentOwner: input.CurrentOwner, CurrentOwnerOrg: ownerOrg, CreatedDate: timeNow, UpdatedDate: timeNow, ProductDescription: input.ProductDescription, ProductCategory: input.ProductCategory, Quantity: input.Quantity, ExpiryDate: input.ExpiryDate, CreatedBy: creator, ParentID: input.ParentID, ComponentIDs: input.ComponentIDs, Manufacturer: manufacturer,
+	}
+
+	if err := s.saveProduct(ctx, &newProduct); err != nil {
+		return nil, err
+	}
+
+	return &newProduct, nil
+}
+
+// validateNewProduct runs every registration check on the input and returns all failures, in the order
+// they are checked. The error result is reserved for failures reading the ledger.
+func (s *SupplyChainSmartContract) validateNewProduct(ctx contractapi.TransactionContextInterface, input ProductInput) ([]error, error) {
+	var problems []error
+	if err := validateProductInput(input.ProductID, input.ProductName, input.CurrentOwner, input.ProductDescription, input.ProductCategory); err != nil {
+		problems = append(problems, err)
+	}
+	if err := s.assertApprovedOwner(ctx, input.CurrentOwner); err != nil {
+		problems = append(problems, err)
+	}
+
+	validID := false
+	if prefix, reserved := reservedKeyPrefix(input.ProductID); reserved {
+		problems = append(problems, fmt.Errorf("invalid product ID %q: the prefix %q is reserved for internal records", input.ProductID, prefix))
+	} else if !isValidProductID(input.ProductID) {
+		problems = append(problems, fmt.Errorf("invalid product ID %q: only letters, digits and hyphens are allowed", input.ProductID))
+	} else {
+		validID = true
+	}
+
+	if input.ProductCategory != "" {
+		if _, err := normalizeCategory(input.ProductCategory); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if input.Quantity < 0 {
+		problems = append(problems, fmt.Errorf("quantity must not be negative, got %d", input.Quantity))
+	}
+	if input.ExpiryDate != "" {
+		expiry, err := time.Parse(time.RFC3339, input.ExpiryDate)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("invalid expiry date %q: %v", input.ExpiryDate, err))
+		} else {
+			now, err := s.fetchTransactionTime(ctx)
+			if err != nil {
+				return nil, err
+			}
+			if !expiry.After(now) {
+				problems = append(problems, fmt.Errorf("expiry date %s is not after the registration time %s; already-expired products cannot be registered", input.ExpiryDate, now.Format(time.RFC3339)))
+			}
+		}
+	}
+
+	if validID {
+		exists, err := s.CheckProductExistence(ctx, input.ProductID)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			problems = append(problems, fmt.Errorf("%w: %s", ErrProductExists, input.ProductID))
+		}
+	}
+
+	return problems, nil
+}
+
+// ValidateProduct runs the RegisterProduct checks on a JSON product without writing anything, so forms
+// can report every problem before the user submits a transaction
+func (s *SupplyChainSmartContract) ValidateProduct(ctx contractapi.TransactionContextInterface, productJSON string) (*ProductValidationResult, error) {
+	result := &ProductValidationResult{Errors: []string{}}
+
+	var input ProductInput
+	if err := json.Unmarshal([]byte(productJSON), &input); err != nil {
+		result.Errors = append(result.Errors, fmt.Sprintf("failed to parse product: %v", err))
+		return result, nil
+	}
+
+	problems, err := s.validateNewProduct(ctx, input)
+	if err != nil {
+		return nil, err
+	}
+	for _, problem := range problems {
+		result.Errors = append(result.Errors, problem.Error())
+	}
+	result.Valid = len(result.Errors) == 0
+
+	return result, nil
+}
+
+// ModifyProduct updates existing product details and reports whether anything changed.
+// When every field is empty or equal to the stored value, nothing is written. The owner field reassigns the
+// product within its owning organization; handing it to another organization requires TransferOwnership
+// or ProposeTransfer/AcceptTransfer, which also move CurrentOwnerOrg.
+// Owner changes are rejected while a proposed transfer is pending.
+func (s *SupplyChainSmartContract) ModifyProduct(ctx contractapi.TransactionContextInterface, id, status, owner, description, category string) (bool, error) {
+	product, err := s.readStoredProduct(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if product == nil {
+		return false, fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+
+	return s.applyProductChanges(ctx, product, status, owner, description, category)
+}
+
+// ModifyProductIfVersion updates product details only if the stored version still matches expectedVersion,
+// giving clients a compare-and-set guard against overwriting changes they have not seen
+func (s *SupplyChainSmartContract) ModifyProductIfVersion(ctx contractapi.TransactionContextInterface, id string, expectedVersion int, status, owner, description, category string) (bool, error) {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return false, err
+	}
+	if product.Version != expectedVersion {
+		return false, fmt.Errorf("%w: product %s is at version %d, expected %d", ErrVersionConflict, id, product.Version, expectedVersion)
+	}
+
+	return s.applyProductChanges(ctx, product, status, owner, description, category)
+}
+
+// applyProductChanges applies the non-empty fields to the product and saves it.
+// It returns false without writing when no field differs from the stored product.
+func (s *SupplyChainSmartContract) applyProductChanges(ctx contractapi.TransactionContextInterface, product *ProductEntity, status, owner, description, category string) (bool, error) {
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return false, err
+	}
+
+	changed := false
+	if status != "" && status != product.ProductStatus {
+		if err := s.validateStatusChange(ctx, product.ProductStatus, status); err != nil {
+			return false, err
+		}
+		product.ProductStatus = status
+		changed = true
+	}
+	if owner != "" && owner != product.CurrentOwner {
+		if err := assertTransferable(product); err != nil {
+			return false, err
+		}
+		if product.PendingOwner != "" {
+			return false, fmt.Errorf("product %s has a pending transfer to %s; accept or reject it first", product.ProductID, product.PendingOwner)
+		}
+		product.CurrentOwner = owner
+		changed = true
+	}
+	if description != "" && description != product.ProductDescription {
+		product.ProductDescription = description
+		changed = true
+	}
+	previousCategory := product.ProductCategory
+	if category != "" {
+		normalized, err := normalizeCategory(category)
+		if err != nil {
+			return false, err
+		}
+		if normalized != product.ProductCategory {
+			product.ProductCategory = normalized
+			changed = true
+		}
+	}
+	if !changed {
+		return false, nil
+	}
+
+	if err := validateProductInput(product.ProductID, product.ProductName, product.CurrentOwner, product.ProductDescription, product.ProductCategory); err != nil {
+		return false, err
+	}
+
+	if previousCategory != product.ProductCategory {
+		if err := s.deleteCategoryIndex(ctx, previousCategory, product.ProductID); err != nil {
+			return false, err
+		}
+	}
+
+	var err error
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return false, err
+	}
+	return true, nil
+}
+
+// UpdateProductStatus moves a product to a new status, enforcing the allowed lifecycle transitions
+func (s *SupplyChainSmartContract) UpdateProductStatus(ctx contractapi.TransactionContextInterface, id, newStatus string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if product.ProductStatus == StatusRecalled && newStatus != StatusDisposed {
+		return fmt.Errorf("product %s is recalled and can only be moved to %s", id, StatusDisposed)
+	}
+	if product.ProductStatus == newStatus {
+		return fmt.Errorf("product %s is already in status %s", id, newStatus)
+	}
+	if err := s.validateStatusChange(ctx, product.ProductStatus, newStatus); err != nil {
+		return err
+	}
+
+	product.ProductStatus = newStatus
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// UpdateLocation records where the product physically is; new products start with no location
+func (s *SupplyChainSmartContract) UpdateLocation(ctx contractapi.TransactionContextInterface, id, location string) error {
+	if strings.TrimSpace(location) == "" {
+		return fmt.Errorf("location must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	product.CurrentLocation = location
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// SetListPrice sets the public list price of a product; negotiated prices belong in private data instead
+func (s *SupplyChainSmartContract) SetListPrice(ctx contractapi.TransactionContextInterface, id string, price float64) error {
+	if price < 0 {
+		return fmt.Errorf("list price must not be negative, got %v", price)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	product.ListPrice = price
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// ReassignCategory moves every product, including archived ones, from oldCategory to newCategory in a
+// single transaction and returns the number updated (admin only). The old category is matched exactly so
+// legacy values outside the current list can be migrated; the new one must be a valid category.
+func (s *SupplyChainSmartContract) ReassignCategory(ctx contractapi.TransactionContextInterface, oldCategory, newCategory string) (int, error) {
+	if err := s.assertAdmin(ctx); err != nil {
+		return 0, err
+	}
+	if oldCategory == "" {
+		return 0, fmt.Errorf("old category must not be empty")
+	}
+	normalized, err := normalizeCategory(newCategory)
+	if err != nil {
+		return 0, err
+	}
+	if normalized == oldCategory {
+		return 0, fmt.Errorf("old and new category are both %s", normalized)
+	}
+
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for _, product := range products {
+		if product.ProductCategory != oldCategory {
+			continue
+		}
+		if err := s.deleteCategoryIndex(ctx, oldCategory, product.ProductID); err != nil {
+			return 0, err
+		}
+		product.ProductCategory = normalized
+		product.UpdatedDate = timeNow
+		if err := s.saveProduct(ctx, product); err != nil {
+			return 0, err
+		}
+		updated++
+	}
+
+	return updated, nil
+}
+
+// UpdateProductDescription replaces only the description of a product; an empty description clears it
+func (s *SupplyChainSmartContract) UpdateProductDescription(ctx contractapi.TransactionContextInterface, id, description string) error {
+	if utf8.RuneCountInString(description) > maxDescriptionLength {
+		return fmt.Errorf("product description exceeds the maximum length of %d characters", maxDescriptionLength)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	product.ProductDescription = description
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// ConsumeQuantity decrements the remaining quantity of a product; a product that runs out becomes Depleted
+func (s *SupplyChainSmartContract) ConsumeQuantity(ctx contractapi.TransactionContextInterface, id string, amount int) error {
+	if amount <= 0 {
+		return fmt.Errorf("amount to consume must be positive, got %d", amount)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if amount > product.Quantity {
+		return fmt.Errorf("cannot consume %d units of product %s: only %d remaining", amount, id, product.Quantity)
+	}
+
+	product.Quantity -= amount
+	if product.Quantity == 0 && validateStatusTransition(product.ProductStatus, StatusDepleted) == nil {
+		product.ProductStatus = StatusDepleted
+	}
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// RecallProduct marks a product as recalled with the given reason; recalled products can no longer change hands
+func (s *SupplyChainSmartContract) RecallProduct(ctx contractapi.TransactionContextInterface, id, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("recall reason must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if product.ProductStatus == StatusRecalled {
+		return fmt.Errorf("product %s is already recalled", id)
+	}
+	if err := validateStatusTransition(product.ProductStatus, StatusRecalled); err != nil {
+		return err
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	product.ProductStatus = StatusRecalled
+	product.RecallReason = reason
+	product.RecalledDate = timeNow
+	product.UpdatedDate = timeNow
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, ProductRecalledEvent, product)
+}
+
+// FlagProduct marks a product as disputed; any participant may raise a flag, and the product
+// cannot change hands until the owner or an admin clears it
+func (s *SupplyChainSmartContract) FlagProduct(ctx contractapi.TransactionContextInterface, id, reason string) error {
+	if strings.TrimSpace(reason) == "" {
+		return fmt.Errorf("flag reason must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if product.Flagged {
+		return fmt.Errorf("product %s is already flagged", id)
+	}
+
+	product.Flagged = true
+	product.FlagReason = reason
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, ProductFlaggedEvent, product)
+}
+
+// ClearFlag resolves a dispute on a product; only the owning organization or an admin may clear it
+func (s *SupplyChainSmartContract) ClearFlag(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		if adminErr := s.assertAdmin(ctx); adminErr != nil {
+			return err
+		}
+	}
+	if !product.Flagged {
+		return fmt.Errorf("product %s is not flagged", id)
+	}
+
+	product.Flagged = false
+	product.FlagReason = ""
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// ListFlaggedProducts returns all products with an unresolved dispute flag using a CouchDB rich query
+func (s *SupplyChainSmartContract) ListFlaggedProducts(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	queryString, err := buildSelectorQuery(map[string]interface{}{"flagged": true})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// BulkUpdateStatus moves every product currently in fromStatus to toStatus in one transaction, e.g. putting
+// all in-transit goods on hold during a lockdown, and returns the number of products updated. Products of
+// every organization are affected, so only the admin organization may call it. The transition is checked
+// once up front; an illegal one changes nothing. Products are found with a range scan rather than a rich
+// query so the peer can detect conflicting writes at commit time.
+func (s *SupplyChainSmartContract) BulkUpdateStatus(ctx contractapi.TransactionContextInterface, fromStatus, toStatus string) (int, error) {
+	if err := s.assertAdmin(ctx); err != nil {
+		return 0, err
+	}
+	if fromStatus == toStatus {
+		return 0, fmt.Errorf("from and to status are both %q", fromStatus)
+	}
+	if err := s.validateQueryStatus(ctx, fromStatus); err != nil {
+		return 0, err
+	}
+	if err := s.validateStatusChange(ctx, fromStatus, toStatus); err != nil {
+		return 0, err
+	}
+
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	updated := []string{}
+	for _, product := range products {
+		if product.ProductStatus != fromStatus {
+			continue
+		}
+		product.ProductStatus = toStatus
+		product.UpdatedDate = timeNow
+		if err := s.saveProduct(ctx, product); err != nil {
+			return 0, err
+		}
+		updated = append(updated, product.ProductID)
+	}
+
+	if err := s.emitEvent(ctx, BulkStatusUpdatedEvent, BulkStatusUpdatedPayload{
+		FromStatus: fromStatus,
+		ToStatus:   toStatus,
+		ProductIDs: updated,
+		Timestamp:  timeNow,
+	}); err != nil {
+		return 0, err
+	}
+	return len(updated), nil
+}
+
+// RecomputeStatus derives a product's status from its data and stores it if it changed, returning the
+// resulting status. Rules in order of precedence: a recall (Recalled status or a recorded recall date) ->
+// Recalled, zero quantity -> Depleted, expiry date passed -> Expired. Legacy records stored without a
+// quantity field are never treated as depleted. Products matching no rule, or already Disposed, Split or
+// ConsumedInAssembly, keep their status.
+func (s *SupplyChainSmartContract) RecomputeStatus(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	productBytes, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return "", fmt.Errorf("error fetching product details: %v", err)
+	}
+	if productBytes == nil {
+		return "", fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+	var product ProductEntity
+	if err := json.Unmarshal(productBytes, &product); err != nil {
+		return "", err
+	}
+	// Records written before quantities were tracked decode to zero without being empty
+	var stored struct {
+		Quantity *int `json:"quantity"`
+	}
+	if err := json.Unmarshal(productBytes, &stored); err != nil {
+		return "", err
+	}
+	if err := s.assertOwnerOrg(ctx, &product); err != nil {
+		return "", err
+	}
+	if allowed, known := statusTransitions[product.ProductStatus]; known && len(allowed) == 0 {
+		return product.ProductStatus, nil
+	}
+
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return "", err
+	}
+	expired, err := isExpired(&product, now)
+	if err != nil {
+		return "", err
+	}
+
+	derived := product.ProductStatus
+	switch {
+	case product.ProductStatus == StatusRecalled || product.RecalledDate != "":
+		derived = StatusRecalled
+	case stored.Quantity != nil && *stored.Quantity == 0:
+		derived = StatusDepleted
+	case expired:
+		derived = StatusExpired
+	}
+	if derived == product.ProductStatus {
+		return product.ProductStatus, nil
+	}
+	if err := validateStatusTransition(product.ProductStatus, derived); err != nil {
+		return "", err
+	}
+
+	product.ProductStatus = derived
+	product.UpdatedDate = now.Format(time.RFC3339)
+	if err := s.saveProduct(ctx, &product); err != nil {
+		return "", err
+	}
+
+	return derived, nil
+}
+
+// ListRecalledProducts returns all products under an active recall
+func (s *SupplyChainSmartContract) ListRecalledProducts(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	return s.GetProductsByStatus(ctx, StatusRecalled)
+}
+
+// SetTemperatureThreshold sets the maximum temperature a product may be exposed to before its cold chain is breached
+func (s *SupplyChainSmartContract) SetTemperatureThreshold(ctx contractapi.TransactionContextInterface, id string, maxTemperature float64) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	product.ColdChain = &ColdChainSettings{MaxTemperature: maxTemperature}
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// RecordSensorReading stores a temperature/humidity reading for a product and flags the product
+// if the temperature exceeds its threshold (or the default threshold when none is set)
+func (s *SupplyChainSmartContract) RecordSensorReading(ctx contractapi.TransactionContextInterface, id string, temperature, humidity float64) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	txTime, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return err
+	}
+	// Nanosecond precision keeps readings from separate transactions in the same second apart
+	readingTime := txTime.UTC().Format(time.RFC3339Nano)
+
+	reading := SensorReading{ProductID: id, Timestamp: readingTime, Temperature: temperature, Humidity: humidity}
+	readingBytes, err := json.Marshal(reading)
+	if err != nil {
+		return err
+	}
+	readingKey, err := ctx.GetStub().CreateCompositeKey(sensorReadingIndex, []string{id, readingTime})
+	if err != nil {
+		return fmt.Errorf("failed to create sensor reading key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(readingKey, readingBytes); err != nil {
+		return fmt.Errorf("failed to store sensor reading for product %s: %v", id, err)
+	}
+
+	if temperature <= temperatureThreshold(product) || product.ColdChainBreached {
+		return nil
+	}
+
+	product.ColdChainBreached = true
+	product.UpdatedDate = txTime.Format(time.RFC3339)
+	return s.saveProduct(ctx, product)
+}
+
+// temperatureThreshold returns a product's cold-chain limit, or the default when none is set
+func temperatureThreshold(product *ProductEntity) float64 {
+	if product.ColdChain != nil {
+		return product.ColdChain.MaxTemperature
+	}
+	return defaultMaxTemperature
+}
+
+// ListColdChainBreaches returns every product whose cold chain has been breached together with the readings
+// above its current threshold, most severe (highest breaching temperature) first. MaxTemperature and
+// WorstBreachTimestamp only consider breaching readings, so they stay empty if the threshold has since been
+// raised above every reading.
+func (s *SupplyChainSmartContract) ListColdChainBreaches(ctx contractapi.TransactionContextInterface) ([]*ColdChainBreach, error) {
+	queryString, err := buildSelectorQuery(map[string]interface{}{"cold_chain_breached": true})
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.getQueryResultForQueryString(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	breaches := make([]*ColdChainBreach, 0, len(products))
+	for _, product := range products {
+		readings, err := s.GetSensorReadings(ctx, product.ProductID)
+		if err != nil {
+			return nil, err
+		}
+
+		breach := &ColdChainBreach{Product: product, Threshold: temperatureThreshold(product), BreachingReadings: []*SensorReading{}}
+		for _, reading := range readings {
+			if reading.Temperature <= breach.Threshold {
+				continue
+			}
+			if len(breach.BreachingReadings) == 0 || reading.Temperature > breach.MaxTemperature {
+				breach.MaxTemperature = reading.Temperature
+				breach.WorstBreachTimestamp = reading.Timestamp
+			}
+			breach.BreachingReadings = append(breach.BreachingReadings, reading)
+		}
+		breaches = append(breaches, breach)
+	}
+
+	sort.SliceStable(breaches, func(i, j int) bool {
+		return breaches[i].MaxTemperature > breaches[j].MaxTemperature
+	})
+
+	return breaches, nil
+}
+
+// GetSensorReadings returns all sensor readings recorded for a product in chronological order
+func (s *SupplyChainSmartContract) GetSensorReadings(ctx contractapi.TransactionContextInterface, id string) (readings []*SensorReading, err error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(sensorReadingIndex, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("error querying sensor readings: %v", err)
+	}
+	defer closeIterator(resultsIterator, &err)
+
+	readings = []*SensorReading{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var reading SensorReading
+		if err := json.Unmarshal(queryResponse.Value, &reading); err != nil {
+			return nil, err
+		}
+		readings = append(readings, &reading)
+	}
+
+	// RFC3339Nano trims trailing zeros, so key order is not reliably chronological
+	sort.SliceStable(readings, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339Nano, readings[i].Timestamp)
+		tj, _ := time.Parse(time.RFC3339Nano, readings[j].Timestamp)
+		return ti.Before(tj)
+	})
+
+	return readings, nil
+}
+
+// AddCertification attaches a compliance certification to a product; adding an existing one is a no-op
+func (s *SupplyChainSmartContract) AddCertification(ctx contractapi.TransactionContextInterface, id, cert string) error {
+	if strings.TrimSpace(cert) == "" {
+		return fmt.Errorf("certification must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	for _, existing := range product.Certifications {
+		if existing == cert {
+			return nil
+		}
+	}
+
+	product.Certifications = append(product.Certifications, cert)
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// RemoveCertification detaches a compliance certification from a product
+func (s *SupplyChainSmartContract) RemoveCertification(ctx contractapi.TransactionContextInterface, id, cert string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0, len(product.Certifications))
+	for _, existing := range product.Certifications {
+		if existing != cert {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == len(product.Certifications) {
+		return fmt.Errorf("product %s does not have certification %q", id, cert)
+	}
+
+	product.Certifications = remaining
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// SetProductMetadata stores a partner-specific key/value on a product; an empty value removes the key
+func (s *SupplyChainSmartContract) SetProductMetadata(ctx contractapi.TransactionContextInterface, id, key, value string) error {
+	if strings.TrimSpace(key) == "" {
+		return fmt.Errorf("metadata key must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	if value == "" {
+		if _, ok := product.Metadata[key]; !ok {
+			return nil
+		}
+		delete(product.Metadata, key)
+	} else {
+		// Records written before metadata existed decode with a nil map
+		if product.Metadata == nil {
+			product.Metadata = make(map[string]string)
+		}
+		product.Metadata[key] = value
+	}
+
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// GetProductMetadata returns the value of a single metadata key on a product
+func (s *SupplyChainSmartContract) GetProductMetadata(ctx contractapi.TransactionContextInterface, id, key string) (string, error) {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return "", err
+	}
+
+	value, ok := product.Metadata[key]
+	if !ok {
+		return "", fmt.Errorf("product %s has no metadata key %q", id, key)
+	}
+	return value, nil
+}
+
+// AnchorDocumentHash records the SHA-256 hash of an off-chain document against a product.
+// An anchored hash cannot be replaced, so later tampering with the document is detectable.
+func (s *SupplyChainSmartContract) AnchorDocumentHash(ctx contractapi.TransactionContextInterface, id, name, hash string) error {
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("document name must not be empty")
+	}
+	if !documentHashPattern.MatchString(hash) {
+		return fmt.Errorf("invalid document hash %q: must be a 64-character hex SHA-256 digest", hash)
+	}
+	hash = strings.ToLower(hash)
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	if existing, ok := product.DocumentHashes[name]; ok {
+		if existing == hash {
+			return nil
+		}
+		return fmt.Errorf("document %q is already anchored on product %s with a different hash", name, id)
+	}
+	// Records written before document anchoring existed decode with a nil map
+	if product.DocumentHashes == nil {
+		product.DocumentHashes = make(map[string]string)
+	}
+	product.DocumentHashes[name] = hash
+
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// VerifyDocumentHash reports whether the given hash matches the one anchored for a document on a product
+func (s *SupplyChainSmartContract) VerifyDocumentHash(ctx contractapi.TransactionContextInterface, id, name, hash string) (bool, error) {
+	if !documentHashPattern.MatchString(hash) {
+		return false, fmt.Errorf("invalid document hash %q: must be a 64-character hex SHA-256 digest", hash)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	anchored, ok := product.DocumentHashes[name]
+	if !ok {
+		return false, fmt.Errorf("product %s has no anchored document %q", id, name)
+	}
+	return anchored == strings.ToLower(hash), nil
+}
+
+// GetStatusHistory returns the status changes of a product in chronological order, starting with its initial status
+func (s *SupplyChainSmartContract) GetStatusHistory(ctx contractapi.TransactionContextInterface, id string) (changes []*StatusChange, err error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(statusLogIndex, []string{id})
+	if err != nil {
+		return nil, fmt.Errorf("error querying status log: %v", err)
+	}
+	defer closeIterator(resultsIterator, &err)
+
+	changes = []*StatusChange{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		var change StatusChange
+		if err := json.Unmarshal(queryResponse.Value, &change); err != nil {
+			return nil, err
+		}
+		changes = append(changes, &change)
+	}
+
+	// Entries are keyed by transaction ID, so order them by time
+	sort.SliceStable(changes, func(i, j int) bool {
+		ti, _ := time.Parse(time.RFC3339Nano, changes[i].Timestamp)
+		tj, _ := time.Parse(time.RFC3339Nano, changes[j].Timestamp)
+		return ti.Before(tj)
+	})
+
+	return changes, nil
+}
+
+// TransferOwnership hands the product to a new owner in the organization with MSP ID newOwnerOrg.
+// From then on only that organization can modify or transfer it.
+func (s *SupplyChainSmartContract) TransferOwnership(ctx contractapi.TransactionContextInterface, id, newOwner, newOwnerOrg string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	previousOwner := product.CurrentOwner
+
+	if err := s.transferProduct(ctx, product, newOwner, newOwnerOrg); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, OwnershipTransferredEvent, OwnershipTransferredPayload{
+		ProductID:     id,
+		PreviousOwner: previousOwner,
+		NewOwner:      newOwner,
+		NewOwnerOrg:   newOwnerOrg,
+		Timestamp:     product.UpdatedDate,
+	})
+}
+
+// TransferOwnershipBatch hands every product in a JSON array of IDs to a new owner in the organization
+// newOwnerOrg. The batch is all-or-nothing: if any product is missing or cannot be transferred, nothing changes.
+func (s *SupplyChainSmartContract) TransferOwnershipBatch(ctx contractapi.TransactionContextInterface, idsJSON, newOwner, newOwnerOrg string) error {
+	var ids []string
+	if err := json.Unmarshal([]byte(idsJSON), &ids); err != nil {
+		return fmt.Errorf("failed to parse product IDs: %v", err)
+	}
+	if len(ids) == 0 {
+		return fmt.Errorf("product ID list must not be empty")
+	}
+
+	return s.transferProducts(ctx, ids, newOwner, newOwnerOrg)
+}
+
+// transferProducts assigns a new owner to every listed product and emits a single batch event
+func (s *SupplyChainSmartContract) transferProducts(ctx contractapi.TransactionContextInterface, ids []string, newOwner, newOwnerOrg string) error {
+	seen := make(map[string]bool, len(ids))
+	for _, id := range ids {
+		if seen[id] {
+			return fmt.Errorf("duplicate product ID %s in batch", id)
+		}
+		seen[id] = true
+
+		product, err := s.RetrieveProduct(ctx, id)
+		if err != nil {
+			return err
+		}
+		if err := s.transferProduct(ctx, product, newOwner, newOwnerOrg); err != nil {
+			return err
+		}
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, BatchOwnershipTransferredEvent, BatchOwnershipTransferredPayload{
+		ProductIDs:  ids,
+		NewOwner:    newOwner,
+		NewOwnerOrg: newOwnerOrg,
+		Timestamp:   timeNow,
+	})
+}
+
+// CreateBundle groups existing products into a kit that can be transferred as one unit.
+// Member products are only referenced, not consumed, and keep their own lifecycle.
+func (s *SupplyChainSmartContract) CreateBundle(ctx contractapi.TransactionContextInterface, bundleID, name, memberIDsJSON string) error {
+	if !isValidProductID(bundleID) {
+		return fmt.Errorf("invalid bundle ID %q: only letters, digits and hyphens are allowed", bundleID)
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("bundle name must not be empty")
+	}
+
+	var memberIDs []string
+	if err := json.Unmarshal([]byte(memberIDsJSON), &memberIDs); err != nil {
+		return fmt.Errorf("failed to parse bundle member IDs: %v", err)
+	}
+	if len(memberIDs) == 0 {
+		return fmt.Errorf("a bundle needs at least one member product")
+	}
+
+	seen := make(map[string]bool, len(memberIDs))
+	for _, id := range memberIDs {
+		if seen[id] {
+			return fmt.Errorf("duplicate member ID %s in bundle", id)
+		}
+		seen[id] = true
+
+		exists, err := s.CheckProductExistence(ctx, id)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return fmt.Errorf("%w: %s", ErrProductNotFound, id)
+		}
+	}
+
+	existing, err := s.readBundle(ctx, bundleID)
+	if err != nil {
+		return err
+	}
+	if existing != nil {
+		return fmt.Errorf("bundle %s already exists", bundleID)
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+	creator, err := s.getClientID(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.putBundle(ctx, &BundleEntity{
+		BundleID:    bundleID,
+		BundleName:  name,
+		MemberIDs:   memberIDs,
+		CreatedDate: timeNow,
+		CreatedBy:   creator,
+	})
+}
+
+// GetBundle returns a bundle and the IDs of its member products
+func (s *SupplyChainSmartContract) GetBundle(ctx contractapi.TransactionContextInterface, bundleID string) (*BundleEntity, error) {
+	bundle, err := s.readBundle(ctx, bundleID)
+	if err != nil {
+		return nil, err
+	}
+	if bundle == nil {
+		return nil, fmt.Errorf("bundle %s does not exist", bundleID)
+	}
+	return bundle, nil
+}
+
+// TransferBundleOwnership assigns a new owner to every member of a bundle in one transaction.
+// It is all-or-nothing: if any member is missing or cannot be transferred, nothing changes.
+func (s *SupplyChainSmartContract) TransferBundleOwnership(ctx contractapi.TransactionContextInterface, bundleID, newOwner, newOwnerOrg string) error {
+	bundle, err := s.GetBundle(ctx, bundleID)
+	if err != nil {
+		return err
+	}
+
+	return s.transferProducts(ctx, bundle.MemberIDs, newOwner, newOwnerOrg)
+}
+
+// readBundle returns the stored bundle, or nil if it does not exist
+func (s *SupplyChainSmartContract) readBundle(ctx contractapi.TransactionContextInterface, bundleID string) (*BundleEntity, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(bundleIndex, []string{bundleID})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create bundle key: %v", err)
+	}
+	bundleBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return nil, fmt.Errorf("error reading bundle %s: %v", bundleID, err)
+	}
+	if bundleBytes == nil {
+		return nil, nil
+	}
+
+	var bundle BundleEntity
+	if err := json.Unmarshal(bundleBytes, &bundle); err != nil {
+		return nil, fmt.Errorf("failed to parse bundle %s: %v", bundleID, err)
+	}
+	return &bundle, nil
+}
+
+// putBundle stores a bundle under its composite key
+func (s *SupplyChainSmartContract) putBundle(ctx contractapi.TransactionContextInterface, bundle *BundleEntity) error {
+	key, err := ctx.GetStub().CreateCompositeKey(bundleIndex, []string{bundle.BundleID})
+	if err != nil {
+		return fmt.Errorf("failed to create bundle key: %v", err)
+	}
+	bundleBytes, err := json.Marshal(bundle)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, bundleBytes)
+}
+
+// ProposeSwap offers to exchange the caller's product id for counterpartID. The swap only takes effect
+// when the counterpart's owning organization accepts it with SwapOwnership; until then neither product's
+// owner may change it through another ownership operation.
+func (s *SupplyChainSmartContract) ProposeSwap(ctx contractapi.TransactionContextInterface, id, counterpartID string) error {
+	if id == counterpartID {
+		return fmt.Errorf("cannot swap product %s with itself", id)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	counterpart, err := s.RetrieveProduct(ctx, counterpartID)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	for _, p := range []*ProductEntity{product, counterpart} {
+		if err := assertTransferable(p); err != nil {
+			return err
+		}
+		if p.PendingOwner != "" {
+			return fmt.Errorf("product %s has a pending transfer to %s; accept or reject it first", p.ProductID, p.PendingOwner)
+		}
+	}
+
+	product.PendingSwapWith = counterpartID
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// SwapOwnership accepts a swap proposed with ProposeSwap(idA, idB), exchanging the owners, and owning
+// organizations, of both products in a single transaction for barter trades. Only the organization owning
+// idB may accept, and neither product may be recalled, flagged or awaiting a pending transfer.
+func (s *SupplyChainSmartContract) SwapOwnership(ctx contractapi.TransactionContextInterface, idA, idB string) error {
+	if idA == idB {
+		return fmt.Errorf("cannot swap product %s with itself", idA)
+	}
+
+	productA, err := s.RetrieveProduct(ctx, idA)
+	if err != nil {
+		return err
+	}
+	productB, err := s.RetrieveProduct(ctx, idB)
+	if err != nil {
+		return err
+	}
+
+	if productA.PendingSwapWith != idB {
+		return fmt.Errorf("product %s has no pending swap with %s", idA, idB)
+	}
+	if err := s.assertOwnerOrg(ctx, productB); err != nil {
+		return fmt.Errorf("%w: only the owner of %s can accept the swap", ErrPermissionDenied, idB)
+	}
+	productA.PendingSwapWith = ""
+	for _, product := range []*ProductEntity{productA, productB} {
+		if err := assertTransferable(product); err != nil {
+			return err
+		}
+		if product.PendingOwner != "" {
+			return fmt.Errorf("product %s has a pending transfer to %s; accept or reject it first", product.ProductID, product.PendingOwner)
+		}
+	}
+
+	timeNow, err := s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	productA.CurrentOwner, productB.CurrentOwner = productB.CurrentOwner, productA.CurrentOwner
+	productA.CurrentOwnerOrg, productB.CurrentOwnerOrg = productB.CurrentOwnerOrg, productA.CurrentOwnerOrg
+	productA.UpdatedDate = timeNow
+	productB.UpdatedDate = timeNow
+
+	if err := s.saveProduct(ctx, productA); err != nil {
+		return err
+	}
+	if err := s.saveProduct(ctx, productB); err != nil {
+		return err
+	}
+	if err := s.setOwnerEndorsementPolicy(ctx, productA); err != nil {
+		return err
+	}
+	if err := s.setOwnerEndorsementPolicy(ctx, productB); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, OwnershipSwappedEvent, OwnershipSwappedPayload{
+		ProductIDA: idA,
+		ProductIDB: idB,
+		Timestamp:  timeNow,
+	})
+}
+
+// RejectSwap cancels the swap pending on product id; the owner of either product may reject it
+func (s *SupplyChainSmartContract) RejectSwap(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if product.PendingSwapWith == "" {
+		return fmt.Errorf("product %s has no pending swap", id)
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		counterpart, readErr := s.RetrieveProduct(ctx, product.PendingSwapWith)
+		if readErr != nil || s.assertOwnerOrg(ctx, counterpart) != nil {
+			return fmt.Errorf("%w: only the owners of %s and %s can reject their swap", ErrPermissionDenied, id, product.PendingSwapWith)
+		}
+	}
+
+	product.PendingSwapWith = ""
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// transferProduct checks that the product may change hands and assigns it to the new owner and owning
+// organization. Transferring to the current owner and organization writes nothing.
+func (s *SupplyChainSmartContract) transferProduct(ctx contractapi.TransactionContextInterface, product *ProductEntity, newOwner, newOwnerOrg string) error {
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if strings.TrimSpace(newOwnerOrg) == "" {
+		return fmt.Errorf("the new owner's organization (MSP ID) must not be empty")
+	}
+	if err := assertTransferable(product); err != nil {
+		return err
+	}
+	if product.PendingOwner != "" {
+		return fmt.Errorf("product %s has a pending transfer to %s; accept or reject it first", product.ProductID, product.PendingOwner)
+	}
+	if newOwner == product.CurrentOwner && newOwnerOrg == product.CurrentOwnerOrg {
+		return nil
+	}
+	if err := validateProductInput(product.ProductID, product.ProductName, newOwner, product.ProductDescription, product.ProductCategory); err != nil {
+		return err
+	}
+
+	product.CurrentOwner = newOwner
+	product.CurrentOwnerOrg = newOwnerOrg
+	var err error
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return err
+	}
+	return s.setOwnerEndorsementPolicy(ctx, product)
+}
+
+// setOwnerEndorsementPolicy sets a key-level endorsement policy on a product so that only peers of its
+// owning organization can endorse further updates. Every path that moves CurrentOwnerOrg must call it.
+// Products without an owning organization keep the chaincode-level policy.
+//
+// The policy applies to every write, whoever submits it. Admin actions on other organizations' products,
+// FlagProduct by a third party and AcceptTransfer by the buyer must therefore also be endorsed by a peer
+// of each owning organization, or they fail validation at commit.
+func (s *SupplyChainSmartContract) setOwnerEndorsementPolicy(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
+	if product.CurrentOwnerOrg == "" {
+		return nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(nil)
+	if err != nil {
+		return err
+	}
+	if err := endorsementPolicy.AddOrgs(statebased.RoleTypePeer, product.CurrentOwnerOrg); err != nil {
+		return fmt.Errorf("failed to build endorsement policy for %s: %v", product.ProductID, err)
+	}
+	policy, err := endorsementPolicy.Policy()
+	if err != nil {
+		return fmt.Errorf("failed to build endorsement policy for %s: %v", product.ProductID, err)
+	}
+
+	if err := ctx.GetStub().SetStateValidationParameter(product.ProductID, policy); err != nil {
+		return fmt.Errorf("failed to set endorsement policy for %s: %v", product.ProductID, err)
+	}
+	return nil
+}
+
+// GetStateEndorsementOrg returns the organizations named in a product's key-level endorsement policy.
+// The list is empty when the product has never changed hands and the chaincode-level policy applies.
+func (s *SupplyChainSmartContract) GetStateEndorsementOrg(ctx contractapi.TransactionContextInterface, id string) ([]string, error) {
+	exists, err := s.CheckProductExistence(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+
+	policy, err := ctx.GetStub().GetStateValidationParameter(id)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read endorsement policy for %s: %v", id, err)
+	}
+	if len(policy) == 0 {
+		return []string{}, nil
+	}
+
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse endorsement policy for %s: %v", id, err)
+	}
+	orgs := endorsementPolicy.ListOrgs()
+	sort.Strings(orgs)
+	return orgs, nil
+}
+
+// DeleteProduct removes a product from the world state, together with its category index entry, sensor
+// readings and status log; its history is kept as a tombstone. Only the owning organization or an admin
+// may delete it.
+func (s *SupplyChainSmartContract) DeleteProduct(ctx contractapi.TransactionContextInterface, id string) error {
+	exists, err := s.CheckProductExistence(ctx, id)
+	if err != nil {
+		return err
+	}
+	if !exists {
+		return fmt.Errorf("cannot delete product: %w: %s", ErrProductNotFound, id)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		if adminErr := s.assertAdmin(ctx); adminErr != nil {
+			return err
+		}
+	}
+	if err := s.deleteCategoryIndex(ctx, product.ProductCategory, id); err != nil {
+		return err
+	}
+	if err := s.deleteByPartialCompositeKey(ctx, sensorReadingIndex, id); err != nil {
+		return err
+	}
+	if err := s.deleteByPartialCompositeKey(ctx, statusLogIndex, id); err != nil {
+		return err
+	}
+
+	if err := ctx.GetStub().DelState(id); err != nil {
+		return fmt.Errorf("failed to delete product %s: %v", id, err)
+	}
+
+	return s.emitEvent(ctx, ProductDeletedEvent, ProductDeletedPayload{ProductID: id})
+}
+
+// DeleteAllProducts wipes every product, together with its category index entry, sensor readings and
+// status log, and returns the number of products deleted. Intended for resetting test networks;
+// only the admin organization may call it. Contract configuration is kept.
+func (s *SupplyChainSmartContract) DeleteAllProducts(ctx contractapi.TransactionContextInterface) (int, error) {
+	if err := s.assertAdmin(ctx); err != nil {
+		return 0, err
+	}
+
+	// Plain range scans skip composite keys, so this only visits products
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return 0, err
+	}
+
+	for _, product := range products {
+		if err := s.deleteCategoryIndex(ctx, product.ProductCategory, product.ProductID); err != nil {
+			return 0, err
+		}
+		if err := s.deleteByPartialCompositeKey(ctx, sensorReadingIndex, product.ProductID); err != nil {
+			return 0, err
+		}
+		if err := s.deleteByPartialCompositeKey(ctx, statusLogIndex, product.ProductID); err != nil {
+			return 0, err
+		}
+		if err := ctx.GetStub().DelState(product.ProductID); err != nil {
+			return 0, fmt.Errorf("failed to delete product %s: %v", product.ProductID, err)
+		}
+	}
+
+	return len(products), nil
+}
+
+// deleteByPartialCompositeKey deletes every composite key of the given type that belongs to a product
+func (s *SupplyChainSmartContract) deleteByPartialCompositeKey(ctx contractapi.TransactionContextInterface, objectType, id string) (err error) {
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(objectType, []string{id})
+	if err != nil {
+		return fmt.Errorf("error querying %s entries: %v", objectType, err)
+	}
+	defer closeIterator(resultsIterator, &err)
+
+	var keys []string
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return err
+		}
+		keys = append(keys, queryResponse.Key)
+	}
+
+	for _, key := range keys {
+		if err := ctx.GetStub().DelState(key); err != nil {
+			return fmt.Errorf("failed to delete %s entry for product %s: %v", objectType, id, err)
+		}
+	}
+	return nil
+}
+
+// ProposeTransfer records a pending transfer that the proposed owner, in the proposed organization (MSP ID),
+// must accept before it takes effect
+func (s *SupplyChainSmartContract) ProposeTransfer(ctx contractapi.TransactionContextInterface, id, proposedOwner, proposedOwnerOrg string) error {
+	if strings.TrimSpace(proposedOwner) == "" {
+		return fmt.Errorf("proposed owner must not be empty")
+	}
+	if strings.TrimSpace(proposedOwnerOrg) == "" {
+		return fmt.Errorf("the proposed owner's organization (MSP ID) must not be empty")
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if err := assertTransferable(product); err != nil {
+		return err
+	}
+	if product.PendingOwner != "" {
+		return fmt.Errorf("product %s already has a pending transfer to %s", id, product.PendingOwner)
+	}
+	if product.CurrentOwner == proposedOwner {
+		return fmt.Errorf("product %s is already owned by %s", id, proposedOwner)
+	}
+
+	product.PendingOwner = proposedOwner
+	product.PendingOwnerOrg = proposedOwnerOrg
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// AcceptTransfer completes a pending transfer; only a caller of the proposed organization acting for the
+// proposed owner may accept. The proposed organization becomes the product's new owning org.
+func (s *SupplyChainSmartContract) AcceptTransfer(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if product.PendingOwner == "" {
+		return fmt.Errorf("product %s has no pending transfer", id)
+	}
+	if err := s.assertPendingOwner(ctx, product); err != nil {
+		return err
+	}
+	if err := assertTransferable(product); err != nil {
+		return err
+	}
+
+	previousOwner := product.CurrentOwner
+	product.CurrentOwner = product.PendingOwner
+	product.CurrentOwnerOrg = product.PendingOwnerOrg
+	product.PendingOwner = ""
+	product.PendingOwnerOrg = ""
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return err
+	}
+	if err := s.setOwnerEndorsementPolicy(ctx, product); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, OwnershipTransferredEvent, OwnershipTransferredPayload{
+		ProductID:     id,
+		PreviousOwner: previousOwner,
+		NewOwner:      product.CurrentOwner,
+		NewOwnerOrg:   product.CurrentOwnerOrg,
+		Timestamp:     product.UpdatedDate,
+	})
+}
+
+// RejectTransfer cancels a pending transfer; either the proposed owner or the current owner may reject it
+func (s *SupplyChainSmartContract) RejectTransfer(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if product.PendingOwner == "" {
+		return fmt.Errorf("product %s has no pending transfer", id)
+	}
+	if err := s.assertPendingOwner(ctx, product); err != nil {
+		if ownerErr := s.assertOwnerOrg(ctx, product); ownerErr != nil {
+			return fmt.Errorf("%w: only the current or proposed owner of product %s can reject its transfer", ErrPermissionDenied, id)
+		}
+	}
+
+	product.PendingOwner = ""
+	product.PendingOwnerOrg = ""
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// SetProductPrivateDetails stores price and cost for a product in a private data collection.
+// Arguments are recorded in the transaction, so clients should leave detailsJSON empty and pass the
+// details in the transient map under "product_details" to keep them out of the block.
+func (s *SupplyChainSmartContract) SetProductPrivateDetails(ctx contractapi.TransactionContextInterface, id, collection, detailsJSON string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+
+	detailsBytes := []byte(detailsJSON)
+	if detailsJSON == "" {
+		transientMap, err := ctx.GetStub().GetTransient()
+		if err != nil {
+			return fmt.Errorf("error reading transient data: %v", err)
+		}
+		detailsBytes = transientMap[privateDetailsTransientKey]
+		if detailsBytes == nil {
+			return fmt.Errorf("private details must be provided as an argument or in the transient map under %q", privateDetailsTransientKey)
+		}
+	}
+
+	var details ProductPrivateDetails
+	if err := json.Unmarshal(detailsBytes, &details); err != nil {
+		return fmt.Errorf("failed to parse private details: %v", err)
+	}
+	if details.Price < 0 || details.SupplierCost < 0 {
+		return fmt.Errorf("price and supplier cost must not be negative")
+	}
+	details.ProductID = id
+
+	privateBytes, err := json.Marshal(details)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutPrivateData(collection, id, privateBytes); err != nil {
+		return fmt.Errorf("failed to store private details for product %s in %s: %v", id, collection, err)
+	}
+	return nil
+}
+
+// GetProductPrivateDetails reads the price and cost of a product from a private data collection
+func (s *SupplyChainSmartContract) GetProductPrivateDetails(ctx contractapi.TransactionContextInterface, collection, id string) (*ProductPrivateDetails, error) {
+	privateBytes, err := ctx.GetStub().GetPrivateData(collection, id)
+	if err != nil {
+		return nil, fmt.Errorf("error reading private details from %s: %v", collection, err)
+	}
+	if privateBytes == nil {
+		return nil, fmt.Errorf("%w: no private details for %s in %s", ErrProductNotFound, id, collection)
+	}
+
+	var details ProductPrivateDetails
+	if err := json.Unmarshal(privateBytes, &details); err != nil {
+		return nil, err
+	}
+
+	return &details, nil
+}
+
+// ArchiveProduct hides a product from default listings while retaining it on the ledger.
+// Archived products can still be fetched directly with RetrieveProduct.
+func (s *SupplyChainSmartContract) ArchiveProduct(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		return err
+	}
+	if product.Archived {
+		return fmt.Errorf("product %s is already archived", id)
+	}
+
+	product.Archived = true
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	return s.saveProduct(ctx, product)
+}
+
+// RestoreProduct reverses ArchiveProduct so the product shows up in listings again; the owning
+// organization or an admin may restore it
+func (s *SupplyChainSmartContract) RestoreProduct(ctx contractapi.TransactionContextInterface, id string) error {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return err
+	}
+	if err := s.assertOwnerOrg(ctx, product); err != nil {
+		if adminErr := s.assertAdmin(ctx); adminErr != nil {
+			return err
+		}
+	}
+	if !product.Archived {
+		return fmt.Errorf("product %s is not archived", id)
+	}
+
+	product.Archived = false
+	product.UpdatedDate, err = s.fetchTransactionTimestamp(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := s.saveProduct(ctx, product); err != nil {
+		return err
+	}
+
+	return s.emitEvent(ctx, ProductRestoredEvent, product)
+}
+
+// RetrieveProduct fetches product details based on the product ID
+func (s *SupplyChainSmartContract) RetrieveProduct(ctx contractapi.TransactionContextInterface, id string) (*ProductEntity, error) {
+	productBytes, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("error fetching product details: %v", err)
+	}
+	if productBytes == nil {
+		return nil, fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+
+	var product ProductEntity
+	if err := json.Unmarshal(productBytes, &product); err != nil {
+		return nil, err
+	}
+
+	return &product, nil
+}
+
+// GetProductJSON returns a product exactly as stored, so clients can pass the bytes through or hash them
+func (s *SupplyChainSmartContract) GetProductJSON(ctx contractapi.TransactionContextInterface, id string) (string, error) {
+	// Composite keys contain null bytes; only product records are served here
+	if strings.ContainsRune(id, 0x00) {
+		return "", fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+
+	productBytes, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return "", fmt.Errorf("error fetching product details: %v", err)
+	}
+	if productBytes == nil {
+		return "", fmt.Errorf("%w: %s", ErrProductNotFound, id)
+	}
+	return string(productBytes), nil
+}
+
+// GetProductWithProof returns a product together with the hex SHA-256 hash of its canonical JSON
+// (object keys sorted, no insignificant whitespace), so the hash does not depend on field order
+func (s *SupplyChainSmartContract) GetProductWithProof(ctx contractapi.TransactionContextInterface, id string) (*ProductProof, error) {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	canonical, err := canonicalJSON(product)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(canonical)
+
+	return &ProductProof{Product: product, Hash: hex.EncodeToString(digest[:])}, nil
+}
+
+// GetRawState returns the raw value stored under any world state key, without decoding it.
+// DEBUG ONLY: intended for diagnosing corrupted records and inspecting non-product keys such as
+// composite index entries. Client applications should use RetrieveProduct instead.
+func (s *SupplyChainSmartContract) GetRawState(ctx contractapi.TransactionContextInterface, key string) (string, error) {
+	stateBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return "", fmt.Errorf("error reading state for key %q: %v", key, err)
+	}
+	if stateBytes == nil {
+		return "", fmt.Errorf("no state found for key %q", key)
+	}
+	return string(stateBytes), nil
+}
+
+// GetProductsByIDs resolves a JSON array of product IDs in one call; missing IDs are reported in NotFound
+func (s *SupplyChainSmartContract) GetProductsByIDs(ctx contractapi.TransactionContextInterface, idsJSON string) (*ProductLookupResult, error) {
+	var ids []string
+	if err := json.Unmarshal([]byte(idsJSON), &ids); err != nil {
+		return nil, fmt.Errorf("failed to parse product IDs: %v", err)
+	}
+
+	result := &ProductLookupResult{Products: []*ProductEntity{}, NotFound: []string{}}
+	for _, id := range ids {
+		product, err := s.RetrieveProduct(ctx, id)
+		if errors.Is(err, ErrProductNotFound) {
+			result.NotFound = append(result.NotFound, id)
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		result.Products = append(result.Products, product)
+	}
+
+	return result, nil
+}
+
+// saveProduct is a utility function to add or update a product in the ledger; every save bumps the product version,
+// stamps the writing transaction ID and appends status changes to the product's status log
+func (s *SupplyChainSmartContract) saveProduct(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
+	stored, err := s.readStoredProduct(ctx, product.ProductID)
+	if err != nil {
+		return err
+	}
+
+	if stored != nil {
+		if err := checkImmutableFields(stored, product); err != nil {
+			return err
+		}
+	}
+	if err := validateTimeline(product); err != nil {
+		return err
+	}
+
+	modifiedBy, err := s.getClientID(ctx)
+	if err != nil {
+		return err
+	}
+	product.LastModifiedBy = modifiedBy
+	product.LastTxID = ctx.GetStub().GetTxID()
+	product.Version++
+
+	productBytes, err := json.Marshal(product)
+	if err != nil {
+		return err
+	}
+	if err := ctx.GetStub().PutState(product.ProductID, productBytes); err != nil {
+		return err
+	}
+
+	if stored == nil || stored.ProductStatus != product.ProductStatus {
+		if err := s.appendStatusChange(ctx, product.ProductID, product.ProductStatus, modifiedBy); err != nil {
+			return err
+		}
+	}
+
+	return s.putCategoryIndex(ctx, product)
+}
+
+// readStoredProduct returns the product currently committed under id, or nil if there is none
+func (s *SupplyChainSmartContract) readStoredProduct(ctx contractapi.TransactionContextInterface, id string) (*ProductEntity, error) {
+	productBytes, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product: %v", err)
+	}
+	if productBytes == nil {
+		return nil, nil
+	}
+
+	var product ProductEntity
+	if err := json.Unmarshal(productBytes, &product); err != nil {
+		return nil, fmt.Errorf("failed to parse stored product %s: %v", id, err)
+	}
+	return &product, nil
+}
+
+// putCategoryIndex writes the category index entry for a product
+func (s *SupplyChainSmartContract) putCategoryIndex(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
+	if product.ProductCategory == "" {
+		return nil
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(categoryIndex, []string{product.ProductCategory, product.ProductID})
+	if err != nil {
+		return fmt.Errorf("failed to create category index key: %v", err)
+	}
+	// The index only needs the key; a single null byte marks the entry as present
+	return ctx.GetStub().PutState(indexKey, []byte{0x00})
+}
+
+// appendStatusChange records a status change in the product's status log
+func (s *SupplyChainSmartContract) appendStatusChange(ctx contractapi.TransactionContextInterface, id, status, changedBy string) error {
+	txTime, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return err
+	}
+
+	change := StatusChange{Status: status, Timestamp: txTime.UTC().Format(time.RFC3339Nano), ChangedBy: changedBy}
+	changeBytes, err := json.Marshal(change)
+	if err != nil {
+		return err
+	}
+	changeKey, err := ctx.GetStub().CreateCompositeKey(statusLogIndex, []string{id, ctx.GetStub().GetTxID()})
+	if err != nil {
+		return fmt.Errorf("failed to create status log key: %v", err)
+	}
+	if err := ctx.GetStub().PutState(changeKey, changeBytes); err != nil {
+		return fmt.Errorf("failed to record status change for product %s: %v", id, err)
+	}
+	return nil
+}
+
+// deleteCategoryIndex removes the category index entry for a product
+func (s *SupplyChainSmartContract) deleteCategoryIndex(ctx contractapi.TransactionContextInterface, category, id string) error {
+	if category == "" {
+		return nil
+	}
+	indexKey, err := ctx.GetStub().CreateCompositeKey(categoryIndex, []string{category, id})
+	if err != nil {
+		return fmt.Errorf("failed to create category index key: %v", err)
+	}
+	if err := ctx.GetStub().DelState(indexKey); err != nil {
+		return fmt.Errorf("failed to delete category index for product %s: %v", id, err)
+	}
+	return nil
+}
+
+// AddApprovedOwner adds an owner to the registration allow-list (admin only)
+func (s *SupplyChainSmartContract) AddApprovedOwner(ctx contractapi.TransactionContextInterface, owner string) error {
+	if err := s.assertAdmin(ctx); err != nil {
+		return err
+	}
+	if strings.TrimSpace(owner) == "" {
+		return fmt.Errorf("owner must not be empty")
+	}
+
+	owners, _, err := s.readApprovedOwners(ctx)
+	if err != nil {
+		return err
+	}
+	for _, existing := range owners {
+		if existing == owner {
+			return nil
+		}
+	}
+
+	owners = append(owners, owner)
+	sort.Strings(owners)
+	return s.putConfig(ctx, approvedOwnersConfig, owners)
+}
+
+// RemoveApprovedOwner removes an owner from the registration allow-list (admin only)
+func (s *SupplyChainSmartContract) RemoveApprovedOwner(ctx contractapi.TransactionContextInterface, owner string) error {
+	if err := s.assertAdmin(ctx); err != nil {
+		return err
+	}
+
+	owners, _, err := s.readApprovedOwners(ctx)
+	if err != nil {
+		return err
+	}
+
+	remaining := make([]string, 0, len(owners))
+	for _, existing := range owners {
+		if existing != owner {
+			remaining = append(remaining, existing)
+		}
+	}
+	if len(remaining) == len(owners) {
+		return fmt.Errorf("owner %s is not on the approved list", owner)
+	}
+
+	return s.putConfig(ctx, approvedOwnersConfig, remaining)
+}
+
+// ListApprovedOwners returns the registration allow-list
+func (s *SupplyChainSmartContract) ListApprovedOwners(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	owners, _, err := s.readApprovedOwners(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return owners, nil
+}
+
+// SetAllowedStatuses replaces the deployment's status list with a JSON array of statuses (admin only).
+// Built-in statuses keep their lifecycle transitions; deployment-specific statuses may be entered from
+// and left to any status.
+func (s *SupplyChainSmartContract) SetAllowedStatuses(ctx contractapi.TransactionContextInterface, statusesJSON string) error {
+	if err := s.assertAdmin(ctx); err != nil {
+		return err
+	}
+
+	var statuses []string
+	if err := json.Unmarshal([]byte(statusesJSON), &statuses); err != nil {
+		return fmt.Errorf("failed to parse statuses: %v", err)
+	}
+	if len(statuses) == 0 {
+		return fmt.Errorf("status list must not be empty")
+	}
+
+	seen := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		if strings.TrimSpace(status) == "" {
+			return fmt.Errorf("status names must not be empty")
+		}
+		if seen[status] {
+			return fmt.Errorf("duplicate status %s", status)
+		}
+		seen[status] = true
+	}
+
+	return s.putConfig(ctx, allowedStatusesConfig, statuses)
+}
+
+// GetAllowedStatuses returns the statuses products may be moved to, falling back to the built-in
+// lifecycle statuses when no list has been configured
+func (s *SupplyChainSmartContract) GetAllowedStatuses(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	var statuses []string
+	configured, err := s.getConfig(ctx, allowedStatusesConfig, &statuses)
+	if err != nil {
+		return nil, err
+	}
+	if !configured {
+		return append([]string{}, defaultStatuses...), nil
+	}
+	return statuses, nil
+}
+
+// SetDefaultStatus sets the status new products start in (admin only); it must be on the allowed status list
+func (s *SupplyChainSmartContract) SetDefaultStatus(ctx contractapi.TransactionContextInterface, status string) error {
+	if err := s.assertAdmin(ctx); err != nil {
+		return err
+	}
+
+	allowed, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	for _, candidate := range allowed {
+		if candidate == status {
+			return s.putConfig(ctx, defaultStatusConfig, status)
+		}
+	}
+	return fmt.Errorf("invalid default status %q: must be one of %s", status, strings.Join(allowed, ", "))
+}
+
+// readDefaultStatus returns the configured initial status, or Manufactured if none is set
+func (s *SupplyChainSmartContract) readDefaultStatus(ctx contractapi.TransactionContextInterface) (string, error) {
+	status := StatusManufactured
+	if _, err := s.getConfig(ctx, defaultStatusConfig, &status); err != nil {
+		return "", err
+	}
+	return status, nil
+}
+
+// validateStatusChange checks a requested status against the configured list and, when it is a
+// built-in status, against the lifecycle transitions
+func (s *SupplyChainSmartContract) validateStatusChange(ctx contractapi.TransactionContextInterface, current, next string) error {
+	allowed, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	found := false
+	for _, status := range allowed {
+		if status == next {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return fmt.Errorf("invalid product status %q: must be one of %s", next, strings.Join(allowed, ", "))
+	}
+
+	if _, builtIn := statusTransitions[next]; !builtIn {
+		return nil
+	}
+	return validateStatusTransition(current, next)
+}
+
+// validateQueryStatus accepts any built-in or configured status, so products left in a status that
+// was later removed from the list can still be found
+func (s *SupplyChainSmartContract) validateQueryStatus(ctx contractapi.TransactionContextInterface, status string) error {
+	allowed, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return err
+	}
+	if !isKnownStatus(status, allowed) {
+		return fmt.Errorf("invalid product status %q", status)
+	}
+	return nil
+}
+
+// assertApprovedOwner rejects owners that are not on the allow-list. Until an admin configures
+// the list, any owner may register products.
+func (s *SupplyChainSmartContract) assertApprovedOwner(ctx contractapi.TransactionContextInterface, owner string) error {
+	owners, configured, err := s.readApprovedOwners(ctx)
+	if err != nil {
+		return err
+	}
+	if !configured {
+		return nil
+	}
+	for _, approved := range owners {
+		if approved == owner {
+			return nil
+		}
+	}
+	return fmt.Errorf("%w: owner %s is not approved to register products", ErrPermissionDenied, owner)
+}
+
+// readApprovedOwners loads the owner allow-list and reports whether it has been configured
+func (s *SupplyChainSmartContract) readApprovedOwners(ctx contractapi.TransactionContextInterface) ([]string, bool, error) {
+	owners := []string{}
+	configured, err := s.getConfig(ctx, approvedOwnersConfig, &owners)
+	if err != nil {
+		return nil, false, err
+	}
+	return owners, configured, nil
+}
+
+// getConfig decodes the named configuration record into value and reports whether it exists
+func (s *SupplyChainSmartContract) getConfig(ctx contractapi.TransactionContextInterface, name string, value interface{}) (bool, error) {
+	key, err := ctx.GetStub().CreateCompositeKey(configIndex, []string{name})
+	if err != nil {
+		return false, fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := ctx.GetStub().GetState(key)
+	if err != nil {
+		return false, fmt.Errorf("error reading %s config: %v", name, err)
+	}
+	if configBytes == nil {
+		return false, nil
+	}
+	if err := json.Unmarshal(configBytes, value); err != nil {
+		return false, fmt.Errorf("failed to parse %s config: %v", name, err)
+	}
+	return true, nil
+}
+
+// putConfig stores the named configuration record
+func (s *SupplyChainSmartContract) putConfig(ctx contractapi.TransactionContextInterface, name string, value interface{}) error {
+	key, err := ctx.GetStub().CreateCompositeKey(configIndex, []string{name})
+	if err != nil {
+		return fmt.Errorf("failed to create config key: %v", err)
+	}
+	configBytes, err := json.Marshal(value)
+	if err != nil {
+		return err
+	}
+	return ctx.GetStub().PutState(key, configBytes)
+}
+
+// assertAdmin verifies that the caller belongs to the admin organization and that its certificate carries
+// the admin attribute; membership of the admin organization alone is not enough
+func (s *SupplyChainSmartContract) assertAdmin(ctx contractapi.TransactionContextInterface) error {
+	clientOrg, err := s.getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != adminMSPID {
+		return fmt.Errorf("%w: only %s can perform this action, caller belongs to %s", ErrPermissionDenied, adminMSPID, clientOrg)
+	}
+	if err := ctx.GetClientIdentity().AssertAttributeValue(adminAttribute, "true"); err != nil {
+		return fmt.Errorf("%w: caller lacks the %s=true attribute: %v", ErrPermissionDenied, adminAttribute, err)
+	}
+	return nil
+}
+
+// getClientID returns the unique X.509 identity (subject and issuer) of the transaction submitter
+func (s *SupplyChainSmartContract) getClientID(ctx contractapi.TransactionContextInterface) (string, error) {
+	clientID, err := ctx.GetClientIdentity().GetID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client identity: %v", err)
+	}
+	return clientID, nil
+}
+
+// getClientOrgID returns the MSP ID of the organization that submitted the transaction
+func (s *SupplyChainSmartContract) getClientOrgID(ctx contractapi.TransactionContextInterface) (string, error) {
+	mspID, err := ctx.GetClientIdentity().GetMSPID()
+	if err != nil {
+		return "", fmt.Errorf("failed to read client MSP ID: %v", err)
+	}
+	return mspID, nil
+}
+
+// assertOwnerOrg verifies that the caller belongs to the organization that owns the product.
+// The caller's MSP ID must equal the product's CurrentOwnerOrg. If the caller's certificate also
+// carries an "owner" attribute, it must match CurrentOwner, which lets an org that manages several
+// owner names restrict each user to their own products. Products registered before owner orgs
+// were tracked have an empty CurrentOwnerOrg; only the admin organization may act on them, for example
+// to transfer them to their owning organization.
+func (s *SupplyChainSmartContract) assertOwnerOrg(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
+	if product.CurrentOwnerOrg == "" {
+		return s.assertAdmin(ctx)
+	}
+
+	clientOrg, err := s.getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != product.CurrentOwnerOrg {
+		return fmt.Errorf("%w: product %s is owned by %s, caller belongs to %s", ErrPermissionDenied, product.ProductID, product.CurrentOwnerOrg, clientOrg)
+	}
+
+	ownerName, found, err := ctx.GetClientIdentity().GetAttributeValue(ownerAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client %s attribute: %v", ownerAttribute, err)
+	}
+	if found && ownerName != product.CurrentOwner {
+		return fmt.Errorf("%w: caller acts for %s but product %s is owned by %s", ErrPermissionDenied, ownerName, product.ProductID, product.CurrentOwner)
+	}
+
+	return nil
+}
+
+// assertCallerActsFor verifies that the caller's certificate carries an "owner" attribute matching the given owner name
+func (s *SupplyChainSmartContract) assertCallerActsFor(ctx contractapi.TransactionContextInterface, owner string) error {
+	ownerName, found, err := ctx.GetClientIdentity().GetAttributeValue(ownerAttribute)
+	if err != nil {
+		return fmt.Errorf("failed to read client %s attribute: %v", ownerAttribute, err)
+	}
+	if !found || ownerName != owner {
+		return fmt.Errorf("%w: caller does not act for %s", ErrPermissionDenied, owner)
+	}
+	return nil
+}
+
+// assertPendingOwner verifies that the caller belongs to the organization a transfer was proposed to and
+// acts for the proposed owner. Transfers proposed before the organization was recorded cannot be accepted
+// and must be proposed again.
+func (s *SupplyChainSmartContract) assertPendingOwner(ctx contractapi.TransactionContextInterface, product *ProductEntity) error {
+	clientOrg, err := s.getClientOrgID(ctx)
+	if err != nil {
+		return err
+	}
+	if clientOrg != product.PendingOwnerOrg {
+		return fmt.Errorf("%w: transfer of product %s was proposed to %s, caller belongs to %s", ErrPermissionDenied, product.ProductID, product.PendingOwnerOrg, clientOrg)
+	}
+	return s.assertCallerActsFor(ctx, product.PendingOwner)
+}
+
+// validateProductInput checks that the required product fields are present and that no field exceeds its
+// length limit; description and category are optional
+func validateProductInput(id, name, owner, description, category string) error {
+	if strings.TrimSpace(id) == "" {
+		return fmt.Errorf("product ID must not be empty")
+	}
+	if strings.TrimSpace(name) == "" {
+		return fmt.Errorf("product name must not be empty")
+	}
+	if strings.TrimSpace(owner) == "" {
+		return fmt.Errorf("product owner must not be empty")
+	}
+
+	fields := []struct {
+		name  string
+		value string
+		limit int
+	}{
+		{"product ID", id, maxProductIDLength},
+		{"product name", name, maxNameLength},
+		{"product owner", owner, maxOwnerLength},
+		{"product description", description, maxDescriptionLength},
+		{"product category", category, maxCategoryLength},
+	}
+	for _, field := range fields {
+		if utf8.RuneCountInString(field.value) > field.limit {
+			return fmt.Errorf("%s exceeds the maximum length of %d characters", field.name, field.limit)
+		}
+	}
+	return nil
+}
+
+// validateStoredProduct applies the registration rules to a product already on the ledger
+func validateStoredProduct(product *ProductEntity, allowedStatuses []string) error {
+	if err := validateProductInput(product.ProductID, product.ProductName, product.CurrentOwner, product.ProductDescription, product.ProductCategory); err != nil {
+		return err
+	}
+	if !isValidProductID(product.ProductID) {
+		return fmt.Errorf("invalid product ID %q: only letters, digits and hyphens are allowed", product.ProductID)
+	}
+	if product.ProductCategory != "" {
+		if _, err := normalizeCategory(product.ProductCategory); err != nil {
+			return err
+		}
+	}
+	if !isKnownStatus(product.ProductStatus, allowedStatuses) {
+		return fmt.Errorf("invalid product status %q", product.ProductStatus)
+	}
+	if product.Quantity < 0 {
+		return fmt.Errorf("quantity must not be negative, got %d", product.Quantity)
+	}
+	if product.ExpiryDate != "" {
+		if _, err := parseFlexibleTimestamp(product.ExpiryDate); err != nil {
+			return fmt.Errorf("invalid expiry date %q: %v", product.ExpiryDate, err)
+		}
+	}
+	return validateTimeline(product)
+}
+
+// checkImmutableFields rejects a save that alters a write-once field of a stored product. A field that
+// is still empty on a legacy record may be filled in once.
+func checkImmutableFields(stored, updated *ProductEntity) error {
+	fields := []struct {
+		name    string
+		stored  string
+		updated string
+	}{
+		{"created_date", stored.CreatedDate, updated.CreatedDate},
+		{"created_by", stored.CreatedBy, updated.CreatedBy},
+		{"manufacturer", stored.Manufacturer, updated.Manufacturer},
+		{"parent_id", stored.ParentID, updated.ParentID},
+	}
+	for _, field := range fields {
+		if field.stored != "" && field.stored != field.updated {
+			return fmt.Errorf("%w: %s of product %s cannot change from %q to %q", ErrImmutableField, field.name, updated.ProductID, field.stored, field.updated)
+		}
+	}
+	return nil
+}
+
+// flexibleTimestampLayouts are the date formats accepted for stored and queried dates, most specific first.
+// Layouts without a zone are read as UTC.
+var flexibleTimestampLayouts = []string{
+	time.RFC3339,
+	time.RFC3339Nano,
+	"2006-01-02T15:04:05",
+	"2006-01-02",
+}
+
+// parseFlexibleTimestamp parses RFC3339 timestamps as well as the older formats found on legacy records
+func parseFlexibleTimestamp(value string) (time.Time, error) {
+	for _, layout := range flexibleTimestampLayouts {
+		if parsed, err := time.Parse(layout, value); err == nil {
+			return parsed, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("unrecognized timestamp %q: expected RFC3339 or YYYY-MM-DD", value)
+}
+
+// validateTimeline rejects a product whose UpdatedDate precedes its CreatedDate, which would mean
+// transaction timestamps were applied out of order; records missing either date are not checked
+func validateTimeline(product *ProductEntity) error {
+	if product.CreatedDate == "" || product.UpdatedDate == "" {
+		return nil
+	}
+
+	created, err := parseFlexibleTimestamp(product.CreatedDate)
+	if err != nil {
+		return fmt.Errorf("invalid created date %q on product %s: %v", product.CreatedDate, product.ProductID, err)
+	}
+	updated, err := parseFlexibleTimestamp(product.UpdatedDate)
+	if err != nil {
+		return fmt.Errorf("invalid updated date %q on product %s: %v", product.UpdatedDate, product.ProductID, err)
+	}
+	if updated.Before(created) {
+		return fmt.Errorf("product %s would be updated at %s, before it was created at %s", product.ProductID, product.UpdatedDate, product.CreatedDate)
+	}
+	return nil
+}
+
+// canonicalJSON marshals a value with object keys sorted at every level; numbers keep their
+// original text so re-encoding does not change them
+func canonicalJSON(value interface{}) ([]byte, error) {
+	raw, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.UseNumber()
+	var generic interface{}
+	if err := decoder.Decode(&generic); err != nil {
+		return nil, fmt.Errorf("failed to canonicalize JSON: %v", err)
+	}
+
+	// encoding/json writes map keys in sorted order
+	return json.Marshal(generic)
+}
+
+// ownerCountKey returns the key a product is counted under in per-owner counts
+func ownerCountKey(product *ProductEntity) string {
+	if strings.TrimSpace(product.CurrentOwner) == "" {
+		return unassignedOwnerKey
+	}
+	return product.CurrentOwner
+}
+
+// sortedKeys returns the keys of a string set in ascending order
+func sortedKeys(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// assertTransferable rejects ownership changes for recalled, disposed or disputed products
+func assertTransferable(product *ProductEntity) error {
+	if product.ProductStatus == StatusRecalled || product.ProductStatus == StatusDisposed {
+		return fmt.Errorf("product %s is %s and cannot change ownership", product.ProductID, product.ProductStatus)
+	}
+	if product.Flagged {
+		return fmt.Errorf("product %s is flagged (%s) and cannot change ownership until the flag is cleared", product.ProductID, product.FlagReason)
+	}
+	if product.PendingSwapWith != "" {
+		return fmt.Errorf("product %s has a pending swap with %s; complete or reject it first", product.ProductID, product.PendingSwapWith)
+	}
+	return nil
+}
+
+// ValidCategories returns the allowed product categories in their canonical casing
+func ValidCategories() []string {
+	categories := make([]string, len(validCategories))
+	copy(categories, validCategories)
+	return categories
+}
+
+// GetContractInfo reports the contract name, version and the statuses and categories it currently accepts
+func (s *SupplyChainSmartContract) GetContractInfo(ctx contractapi.TransactionContextInterface) (*ContractInfo, error) {
+	statuses, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ContractInfo{
+		Name:       ContractName,
+		Version:    ContractVersion,
+		Statuses:   statuses,
+		Categories: ValidCategories(),
+	}, nil
+}
+
+// GetValidCategories exposes the allowed product categories to clients
+func (s *SupplyChainSmartContract) GetValidCategories(ctx contractapi.TransactionContextInterface) []string {
+	return ValidCategories()
+}
+
+// normalizeCategory matches a category case-insensitively and returns its canonical casing
+func normalizeCategory(category string) (string, error) {
+	for _, valid := range validCategories {
+		if strings.EqualFold(strings.TrimSpace(category), valid) {
+			return valid, nil
+		}
+	}
+	return "", fmt.Errorf("invalid category %q: must be one of %s", category, strings.Join(validCategories, ", "))
+}
+
+// isCompositeKey reports whether a state key is a composite index or internal record key
+func isCompositeKey(key string) bool {
+	return strings.HasPrefix(key, compositeKeyPrefix)
+}
+
+// reservedKeyPrefix reports the reserved prefix an ID starts with, if any
+func reservedKeyPrefix(id string) (string, bool) {
+	for _, prefix := range reservedKeyPrefixes {
+		if strings.HasPrefix(id, prefix) {
+			return prefix, true
+		}
+	}
+	return "", false
+}
+
+// isValidProductID reports whether the ID is safe to use as a ledger key and composite key attribute
+func isValidProductID(id string) bool {
+	// Fabric delimits composite key attributes with a null byte
+	if strings.ContainsRune(id, 0x00) {
+		return false
+	}
+	return productIDPattern.MatchString(id)
+}
+
+// isExpired reports whether the product expired before now; products without an expiry date never expire
+func isExpired(product *ProductEntity, now time.Time) (bool, error) {
+	if product.ExpiryDate == "" {
+		return false, nil
+	}
+	expiry, err := parseFlexibleTimestamp(product.ExpiryDate)
+	if err != nil {
+		return false, fmt.Errorf("product %s has an invalid expiry date %q: %v", product.ProductID, product.ExpiryDate, err)
+	}
+	return !now.Before(expiry), nil
+}
+
+// validateStatus checks that the status is one of the known lifecycle statuses
+func validateStatus(status string) error {
+	if _, known := statusTransitions[status]; !known {
+		return fmt.Errorf("invalid product status %q", status)
+	}
+	return nil
+}
+
+// isKnownStatus reports whether the status is built in or part of the configured list
+func isKnownStatus(status string, configured []string) bool {
+	if validateStatus(status) == nil {
+		return true
+	}
+	for _, allowed := range configured {
+		if allowed == status {
+			return true
+		}
+	}
+	return false
+}
+
+// validateStatusTransition checks that moving from the current status to the next one is allowed.
+// Products carrying a status from before the state machine existed may move to any known status.
+func validateStatusTransition(current, next string) error {
+	if err := validateStatus(next); err != nil {
+		return err
+	}
+	allowed, known := statusTransitions[current]
+	if !known {
+		return nil
+	}
+	for _, status := range allowed {
+		if status == next {
+			return nil
+		}
+	}
+	return fmt.Errorf("illegal status transition for product: %s -> %s", current, next)
+}
+
+// emitEvent marshals the payload and sets it as the chaincode event for the transaction
+func (s *SupplyChainSmartContract) emitEvent(ctx contractapi.TransactionContextInterface, name string, payload interface{}) error {
+	payloadBytes, err := json.Marshal(payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s event: %v", name, err)
+	}
+	if err := ctx.GetStub().SetEvent(name, payloadBytes); err != nil {
+		return fmt.Errorf("failed to emit %s event: %v", name, err)
+	}
+	return nil
+}
+
+// CheckProductExistence verifies if a product exists in the ledger
+func (s *SupplyChainSmartContract) CheckProductExistence(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	productBytes, err := ctx.GetStub().GetState(id)
+	if err != nil {
+		return false, fmt.Errorf("error checking product existence: %v", err)
+	}
+	return productBytes != nil, nil
+}
+
+// ListAllProducts retrieves all products from the ledger, excluding archived ones, ordered by product ID
+func (s *SupplyChainSmartContract) ListAllProducts(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	return s.listProducts(ctx, false)
+}
+
+// ListAllProductsIncludingArchived retrieves all products from the ledger, including archived ones
+func (s *SupplyChainSmartContract) ListAllProductsIncludingArchived(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	return s.listProducts(ctx, true)
+}
+
+// listProducts scans the world state for products, optionally keeping archived ones. The range scan
+// returns keys in lexical order, so products come back sorted by ID.
+func (s *SupplyChainSmartContract) listProducts(ctx contractapi.TransactionContextInterface, includeArchived bool) ([]*ProductEntity, error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return nil, err
+	}
+
+	allProducts, err := collectProducts(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+	if includeArchived {
+		return allProducts, nil
+	}
+
+	products := []*ProductEntity{}
+	for _, product := range allProducts {
+		if !product.Archived {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// GetProductCount returns the number of products on the ledger without decoding them
+func (s *SupplyChainSmartContract) GetProductCount(ctx contractapi.TransactionContextInterface) (count int, err error) {
+	resultsIterator, err := ctx.GetStub().GetStateByRange("", "")
+	if err != nil {
+		return 0, err
+	}
+	defer closeIterator(resultsIterator, &err)
+
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return 0, err
+		}
+		if isCompositeKey(queryResponse.Key) {
+			continue
+		}
+		count++
+	}
+
+	return count, nil
+}
+
+// GetLedgerStatistics counts all products, grouped by status and by category, in a single pass over the ledger
+func (s *SupplyChainSmartContract) GetLedgerStatistics(ctx contractapi.TransactionContextInterface) (*LedgerStatistics, error) {
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &LedgerStatistics{
+		CountByStatus:   map[string]int{},
+		CountByCategory: map[string]int{},
+	}
+	for _, product := range products {
+		stats.TotalCount++
+		stats.CountByStatus[product.ProductStatus]++
+		stats.CountByCategory[product.ProductCategory]++
+	}
+
+	return stats, nil
+}
+
+// GetStatusFunnel counts the non-archived products in each status, ordered like the allowed status list
+// (lifecycle order by default) and including statuses with no products. Statuses found on products but
+// no longer on the list are appended in alphabetical order so no product goes uncounted.
+func (s *SupplyChainSmartContract) GetStatusFunnel(ctx contractapi.TransactionContextInterface) ([]*StatusCount, error) {
+	statuses, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, product := range products {
+		counts[product.ProductStatus]++
+	}
+
+	funnel := make([]*StatusCount, 0, len(statuses))
+	for _, status := range statuses {
+		funnel = append(funnel, &StatusCount{Status: status, Count: counts[status]})
+		delete(counts, status)
+	}
+	unlisted := make([]string, 0, len(counts))
+	for status := range counts {
+		unlisted = append(unlisted, status)
+	}
+	sort.Strings(unlisted)
+	for _, status := range unlisted {
+		funnel = append(funnel, &StatusCount{Status: status, Count: counts[status]})
+	}
+
+	return funnel, nil
+}
+
+// GetProductCountByOwner counts the non-archived products held by each owner in a single scan.
+// Products without an owner are counted under "(no owner)" so they are not silently dropped.
+func (s *SupplyChainSmartContract) GetProductCountByOwner(ctx contractapi.TransactionContextInterface) (map[string]int, error) {
+	products, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	counts := map[string]int{}
+	for _, product := range products {
+		counts[ownerCountKey(product)]++
+	}
+	return counts, nil
+}
+
+// CountProductsForOwner counts the non-archived products held by one owner; pass "(no owner)" to
+// count products without an owner
+func (s *SupplyChainSmartContract) CountProductsForOwner(ctx contractapi.TransactionContextInterface, owner string) (int, error) {
+	products, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, product := range products {
+		if ownerCountKey(product) == owner {
+			count++
+		}
+	}
+	return count, nil
+}
+
+// GetDistinctOwners returns the sorted, deduplicated owners of the non-archived products
+func (s *SupplyChainSmartContract) GetDistinctOwners(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	owners, _, err := s.distinctOwnersAndCategories(ctx)
+	return owners, err
+}
+
+// GetDistinctCategories returns the sorted, deduplicated categories in use by non-archived products
+func (s *SupplyChainSmartContract) GetDistinctCategories(ctx contractapi.TransactionContextInterface) ([]string, error) {
+	_, categories, err := s.distinctOwnersAndCategories(ctx)
+	return categories, err
+}
+
+// distinctOwnersAndCategories collects both dropdown value lists in a single pass; empty values are left out
+func (s *SupplyChainSmartContract) distinctOwnersAndCategories(ctx contractapi.TransactionContextInterface) ([]string, []string, error) {
+	products, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ownerSet := map[string]bool{}
+	categorySet := map[string]bool{}
+	for _, product := range products {
+		if product.CurrentOwner != "" {
+			ownerSet[product.CurrentOwner] = true
+		}
+		if product.ProductCategory != "" {
+			categorySet[product.ProductCategory] = true
+		}
+	}
+
+	return sortedKeys(ownerSet), sortedKeys(categorySet), nil
+}
+
+// ListProductsMissingOwner returns the products, including archived ones, whose owner is empty or blank
+func (s *SupplyChainSmartContract) ListProductsMissingOwner(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	missingOwner := []*ProductEntity{}
+	for _, product := range products {
+		if strings.TrimSpace(product.CurrentOwner) == "" {
+			missingOwner = append(missingOwner, product)
+		}
+	}
+	return missingOwner, nil
+}
+
+// ValidateLedgerIntegrity checks every stored product, including archived ones, against the current
+// validation rules and reports the ones that fail so legacy data can be remediated
+func (s *SupplyChainSmartContract) ValidateLedgerIntegrity(ctx contractapi.TransactionContextInterface) (*IntegrityReport, error) {
+	products, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	allowedStatuses, err := s.GetAllowedStatuses(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &IntegrityReport{CheckedCount: len(products), Issues: []*IntegrityIssue{}}
+	for _, product := range products {
+		if err := validateStoredProduct(product, allowedStatuses); err != nil {
+			report.Issues = append(report.Issues, &IntegrityIssue{ProductID: product.ProductID, Problem: err.Error()})
+		}
+	}
+	return report, nil
+}
+
+// GetProductsByDateRange returns products whose CreatedDate falls within the inclusive RFC3339 range.
+// Legacy records without a CreatedDate are skipped.
+func (s *SupplyChainSmartContract) GetProductsByDateRange(ctx contractapi.TransactionContextInterface, startRFC3339, endRFC3339 string) ([]*ProductEntity, error) {
+	start, err := parseFlexibleTimestamp(startRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %q: %v", startRFC3339, err)
+	}
+	end, err := parseFlexibleTimestamp(endRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %q: %v", endRFC3339, err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endRFC3339, startRFC3339)
+	}
+
+	allProducts, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []*ProductEntity{}
+	for _, product := range allProducts {
+		if product.CreatedDate == "" {
+			continue
+		}
+		created, err := parseFlexibleTimestamp(product.CreatedDate)
+		if err != nil {
+			return nil, fmt.Errorf("product %s has an invalid created date %q: %v", product.ProductID, product.CreatedDate, err)
+		}
+		if !created.Before(start) && !created.After(end) {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+// ListProductsOlderThan returns products, including archived ones, created more than the given number of
+// days before the transaction timestamp. Records with a missing or unparseable CreatedDate are excluded
+// and reported in UnparseableIDs.
+func (s *SupplyChainSmartContract) ListProductsOlderThan(ctx contractapi.TransactionContextInterface, days int) (*AgedProductsResult, error) {
+	if days <= 0 {
+		return nil, fmt.Errorf("days must be positive, got %d", days)
+	}
+
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+	cutoff := now.AddDate(0, 0, -days)
+
+	allProducts, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &AgedProductsResult{Products: []*ProductEntity{}, UnparseableIDs: []string{}}
+	for _, product := range allProducts {
+		created, err := parseFlexibleTimestamp(product.CreatedDate)
+		if err != nil {
+			result.UnparseableIDs = append(result.UnparseableIDs, product.ProductID)
+			continue
+		}
+		if created.Before(cutoff) {
+			result.Products = append(result.Products, product)
+		}
+	}
+
+	return result, nil
+}
+
+// GetProductsModifiedAfter returns products updated strictly after the given RFC3339 time, oldest change first.
+// Archived products are included so that caches also see archive changes; records without a
+// parseable UpdatedDate are skipped.
+func (s *SupplyChainSmartContract) GetProductsModifiedAfter(ctx contractapi.TransactionContextInterface, sinceRFC3339 string) ([]*ProductEntity, error) {
+	since, err := parseFlexibleTimestamp(sinceRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid since time %q: %v", sinceRFC3339, err)
+	}
+
+	allProducts, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+
+	type modifiedProduct struct {
+		product   *ProductEntity
+		updatedAt time.Time
+	}
+	var modified []modifiedProduct
+	for _, product := range allProducts {
+		updatedAt, err := parseFlexibleTimestamp(product.UpdatedDate)
+		if err != nil {
+			continue
+		}
+		if updatedAt.After(since) {
+			modified = append(modified, modifiedProduct{product: product, updatedAt: updatedAt})
+		}
+	}
+
+	sort.SliceStable(modified, func(i, j int) bool {
+		return modified[i].updatedAt.Before(modified[j].updatedAt)
+	})
+
+	products := make([]*ProductEntity, 0, len(modified))
+	for _, m := range modified {
+		products = append(products, m.product)
+	}
+
+	return products, nil
+}
+
+// ListExpiredProducts returns products whose expiry date has passed as of the transaction timestamp
+func (s *SupplyChainSmartContract) ListExpiredProducts(ctx contractapi.TransactionContextInterface) ([]*ProductEntity, error) {
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allProducts, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	products := []*ProductEntity{}
+	for _, product := range allProducts {
+		expired, err := isExpired(product, now)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			products = append(products, product)
+		}
+	}
+
+	return products, nil
+}
+
+// GetAlertProducts returns, in one pass, the non-archived products that are recalled, flagged, expired
+// or have a cold-chain breach, each annotated with every reason that applies
+func (s *SupplyChainSmartContract) GetAlertProducts(ctx contractapi.TransactionContextInterface) ([]*AlertProduct, error) {
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	allProducts, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	alerts := []*AlertProduct{}
+	for _, product := range allProducts {
+		var reasons []string
+		if product.ProductStatus == StatusRecalled {
+			reasons = append(reasons, AlertRecalled)
+		}
+		if product.Flagged {
+			reasons = append(reasons, AlertFlagged)
+		}
+		expired, err := isExpired(product, now)
+		if err != nil {
+			return nil, err
+		}
+		if expired {
+			reasons = append(reasons, AlertExpired)
+		}
+		if product.ColdChainBreached {
+			reasons = append(reasons, AlertColdChainBreach)
+		}
+
+		if len(reasons) > 0 {
+			alerts = append(alerts, &AlertProduct{Product: product, AlertReasons: reasons})
+		}
+	}
+
+	return alerts, nil
+}
+
+// IsProductExpired reports whether a product's expiry date has passed as of the transaction timestamp
+func (s *SupplyChainSmartContract) IsProductExpired(ctx contractapi.TransactionContextInterface, id string) (bool, error) {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return false, err
+	}
+
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	return isExpired(product, now)
+}
+
+// ListProductsWithPagination retrieves one page of products; pass the returned bookmark to fetch the next page
+func (s *SupplyChainSmartContract) ListProductsWithPagination(ctx contractapi.TransactionContextInterface, pageSize int32, bookmark string) (*PaginatedProductsResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be greater than zero, got %d", pageSize)
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetStateByRangeWithPagination("", "", pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product page: %v", err)
+	}
+
+	products, err := collectProducts(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedProductsResult{
+		Products:            products,
+		Bookmark:            responseMetadata.Bookmark,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+	}, nil
+}
+
+// GetProductsByCategory returns all products in a category using the category~id composite key index.
+// The category is matched case-insensitively and must be one of the valid categories.
+func (s *SupplyChainSmartContract) GetProductsByCategory(ctx contractapi.TransactionContextInterface, category string) (products []*ProductEntity, err error) {
+	normalizedCategory, err := normalizeCategory(category)
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, err := ctx.GetStub().GetStateByPartialCompositeKey(categoryIndex, []string{normalizedCategory})
+	if err != nil {
+		return nil, fmt.Errorf("error querying category index: %v", err)
+	}
+	defer closeIterator(resultsIterator, &err)
+
+	products = []*ProductEntity{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		_, keyParts, err := ctx.GetStub().SplitCompositeKey(queryResponse.Key)
+		if err != nil {
+			return nil, fmt.Errorf("failed to split category index key: %v", err)
+		}
+		if len(keyParts) != 2 {
+			continue
+		}
+
+		product, err := s.RetrieveProduct(ctx, keyParts[1])
+		if err != nil {
+			return nil, err
+		}
+		products = append(products, product)
+	}
+
+	return products, nil
+}
+
+// GetProductHistory returns every recorded state of a product, including deletions, oldest first
+func (s *SupplyChainSmartContract) GetProductHistory(ctx contractapi.TransactionContextInterface, id string) ([]*ProductHistoryEntry, error) {
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	history := make([]*ProductHistoryEntry, 0, len(records))
+	for _, record := range records {
+		history = append(history, record.entry)
+	}
+
+	return history, nil
+}
+
+// GetProductHistoryPaged returns up to pageSize history entries of a product, oldest first, starting at
+// startIndex. The history iterator has no bookmark and must be sorted, so every call still reads the
+// product's full history (O(n)); paging only bounds the size of the response.
+func (s *SupplyChainSmartContract) GetProductHistoryPaged(ctx contractapi.TransactionContextInterface, id string, pageSize int32, startIndex int) (*ProductHistoryPage, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be greater than zero, got %d", pageSize)
+	}
+	if startIndex < 0 {
+		return nil, fmt.Errorf("start index must not be negative, got %d", startIndex)
+	}
+
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	page := &ProductHistoryPage{Entries: []*ProductHistoryEntry{}}
+	if startIndex >= len(records) {
+		return page, nil
+	}
+	end := startIndex + int(pageSize)
+	if end > len(records) {
+		end = len(records)
+	}
+	for _, record := range records[startIndex:end] {
+		page.Entries = append(page.Entries, record.entry)
+	}
+	page.HasMore = end < len(records)
+
+	return page, nil
+}
+
+// GetProductWithHistory returns the current state of a product together with up to historyLimit of its
+// most recent history entries, saving clients a second round-trip. A limit of zero skips the history lookup.
+func (s *SupplyChainSmartContract) GetProductWithHistory(ctx contractapi.TransactionContextInterface, id string, historyLimit int) (*ProductWithHistory, error) {
+	if historyLimit < 0 {
+		return nil, fmt.Errorf("history limit must not be negative, got %d", historyLimit)
+	}
+
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &ProductWithHistory{Product: product, History: []*ProductHistoryEntry{}}
+	if historyLimit == 0 {
+		return result, nil
+	}
+
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(records) > historyLimit {
+		records = records[len(records)-historyLimit:]
+		result.Truncated = true
+	}
+	for _, record := range records {
+		result.History = append(result.History, record.entry)
+	}
+
+	return result, nil
+}
+
+// historyRecord pairs a history entry with its parsed commit time
+type historyRecord struct {
+	entry *ProductHistoryEntry
+	time  time.Time
+}
+
+// readProductHistory walks the history of a product and returns it sorted oldest first
+func (s *SupplyChainSmartContract) readProductHistory(ctx contractapi.TransactionContextInterface, id string) (records []historyRecord, err error) {
+	historyIterator, err := ctx.GetStub().GetHistoryForKey(id)
+	if err != nil {
+		return nil, fmt.Errorf("error retrieving product history: %v", err)
+	}
+	defer closeIterator(historyIterator, &err)
+
+	for historyIterator.HasNext() {
+		modification, err := historyIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+
+		entry := ProductHistoryEntry{
+			TxID:      modification.TxId,
+			IsDeleted: modification.IsDelete,
+		}
+		var modifiedAt time.Time
+		if modification.Timestamp != nil {
+			modifiedAt = time.Unix(modification.Timestamp.Seconds, int64(modification.Timestamp.Nanos)).UTC()
+			entry.Timestamp = modifiedAt.Format(time.RFC3339)
+		}
+
+		// Deleted entries carry no value, so only parse live states
+		if !modification.IsDelete && modification.Value != nil {
+			var product ProductEntity
+			if err := json.Unmarshal(modification.Value, &product); err != nil {
+				return nil, fmt.Errorf("failed to parse product history for %s: %v", id, err)
+			}
+			entry.Product = &product
+		}
+
+		records = append(records, historyRecord{entry: &entry, time: modifiedAt})
+	}
+
+	if len(records) == 0 {
+		return nil, fmt.Errorf("%w: %s has no history on the ledger", ErrProductNotFound, id)
+	}
+
+	// The peer does not guarantee an iteration order, so sort by commit time
+	sort.SliceStable(records, func(i, j int) bool {
+		return records[i].time.Before(records[j].time)
+	})
+
+	return records, nil
+}
+
+// GetProductAtTime returns the state of a product as it was at the given RFC3339 time
+func (s *SupplyChainSmartContract) GetProductAtTime(ctx contractapi.TransactionContextInterface, id, targetRFC3339 string) (*ProductEntity, error) {
+	target, err := parseFlexibleTimestamp(targetRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target time %q: %v", targetRFC3339, err)
+	}
+
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	var latest *ProductHistoryEntry
+	for _, record := range records {
+		if record.time.After(target) {
+			break
+		}
+		latest = record.entry
+	}
+
+	if latest == nil || latest.IsDeleted || latest.Product == nil {
+		return nil, fmt.Errorf("%w: %s did not exist at %s", ErrProductNotFound, id, targetRFC3339)
+	}
+
+	return latest.Product, nil
+}
+
+// GetOwnershipChain returns the sequence of owners of a product and when each took ownership
+func (s *SupplyChainSmartContract) GetOwnershipChain(ctx contractapi.TransactionContextInterface, id string) ([]*OwnershipRecord, error) {
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	chain := []*OwnershipRecord{}
+	for _, record := range records {
+		if record.entry.Product == nil {
+			continue
+		}
+		owner := record.entry.Product.CurrentOwner
+		if len(chain) > 0 && chain[len(chain)-1].Owner == owner {
+			continue
+		}
+		chain = append(chain, &OwnershipRecord{Owner: owner, FromTimestamp: record.entry.Timestamp})
+	}
+
+	return chain, nil
+}
+
+// GetOwnershipDurations reconstructs from history how long each owner held a product, oldest first.
+// A holding period ends at the next transfer or deletion; the current owner's runs up to this transaction.
+func (s *SupplyChainSmartContract) GetOwnershipDurations(ctx contractapi.TransactionContextInterface, id string) ([]*OwnershipDuration, error) {
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now, err := s.fetchTransactionTime(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	durations := []*OwnershipDuration{}
+	var owner string
+	var since time.Time
+	holding := false
+	closeHolding := func(until time.Time) {
+		if holding {
+			durations = append(durations, &OwnershipDuration{Owner: owner, DurationSeconds: int64(until.Sub(since).Seconds())})
+		}
+		holding = false
+	}
+	for _, record := range records {
+		product := record.entry.Product
+		if product == nil {
+			closeHolding(record.time)
+			continue
+		}
+		if holding && product.CurrentOwner == owner {
+			continue
+		}
+		closeHolding(record.time)
+		owner, since, holding = product.CurrentOwner, record.time, true
+	}
+	closeHolding(now)
+
+	return durations, nil
+}
+
+// GetTransferReceipt reconstructs the latest handover of a product from its history. A product that has
+// never changed hands yields a receipt with Transferred set to false.
+func (s *SupplyChainSmartContract) GetTransferReceipt(ctx contractapi.TransactionContextInterface, id string) (*TransferReceipt, error) {
+	records, err := s.readProductHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	receipt := &TransferReceipt{ProductID: id}
+	transfers := ownershipTransfers(id, records)
+	if len(transfers) > 0 {
+		latest := transfers[len(transfers)-1].record
+		receipt.Transferred = true
+		receipt.PreviousOwner = latest.FromOwner
+		receipt.NewOwner = latest.ToOwner
+		receipt.Timestamp = latest.Timestamp
+		receipt.TxID = latest.TxID
+	}
+
+	return receipt, nil
+}
+
+// GetTransfersBetween returns the ownership transfers from one owner to another that happened within the
+// inclusive RFC3339 window. Walking history is expensive, so productIDsJSON may list the products to
+// check; an empty value scans every product currently on the ledger.
+func (s *SupplyChainSmartContract) GetTransfersBetween(ctx contractapi.TransactionContextInterface, fromOwner, toOwner, startRFC3339, endRFC3339, productIDsJSON string) ([]*TransferRecord, error) {
+	if fromOwner == "" || toOwner == "" {
+		return nil, fmt.Errorf("both the from and to owner are required")
+	}
+	start, err := parseFlexibleTimestamp(startRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %q: %v", startRFC3339, err)
+	}
+	end, err := parseFlexibleTimestamp(endRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %q: %v", endRFC3339, err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endRFC3339, startRFC3339)
+	}
+
+	var ids []string
+	if productIDsJSON != "" {
+		if err := json.Unmarshal([]byte(productIDsJSON), &ids); err != nil {
+			return nil, fmt.Errorf("failed to parse product IDs: %v", err)
+		}
+	}
+	if len(ids) == 0 {
+		products, err := s.listProducts(ctx, true)
+		if err != nil {
+			return nil, err
+		}
+		for _, product := range products {
+			ids = append(ids, product.ProductID)
+		}
+	}
+
+	matches := []*TransferRecord{}
+	for _, id := range ids {
+		records, err := s.readProductHistory(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		for _, transfer := range ownershipTransfers(id, records) {
+			if transfer.record.FromOwner != fromOwner || transfer.record.ToOwner != toOwner {
+				continue
+			}
+			if transfer.time.Before(start) || transfer.time.After(end) {
+				continue
+			}
+			matches = append(matches, transfer.record)
+		}
+	}
+
+	return matches, nil
+}
+
+// ownershipTransfer is a transfer found in history together with its parsed commit time
+type ownershipTransfer struct {
+	record *TransferRecord
+	time   time.Time
+}
+
+// ownershipTransfers lists the owner changes in a product's sorted history, oldest first
+func ownershipTransfers(id string, records []historyRecord) []ownershipTransfer {
+	var transfers []ownershipTransfer
+	var previous *ProductEntity
+	for _, record := range records {
+		product := record.entry.Product
+		if product == nil {
+			continue
+		}
+		if previous != nil && previous.CurrentOwner != product.CurrentOwner {
+			transfers = append(transfers, ownershipTransfer{
+				record: &TransferRecord{
+					ProductID: id,
+					FromOwner: previous.CurrentOwner,
+					ToOwner:   product.CurrentOwner,
+					Timestamp: record.entry.Timestamp,
+					TxID:      record.entry.TxID,
+				},
+				time: record.time,
+			})
+		}
+		previous = product
+	}
+	return transfers
+}
+
+// GetProductLineage resolves the ancestors (parents and assembly components) and descendants (split children
+// and assemblies) of a product into a graph. Each direction is followed for at most maxLineageDepth
+// generations; Truncated reports whether links beyond the cap were left out. Products are visited once, so
+// cycles terminate.
+func (s *SupplyChainSmartContract) GetProductLineage(ctx contractapi.TransactionContextInterface, id string) (*ProductLineage, error) {
+	root, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Descendants are only recorded on the derived products, so index the whole ledger once
+	allProducts, err := s.listProducts(ctx, true)
+	if err != nil {
+		return nil, err
+	}
+	byID := make(map[string]*ProductEntity, len(allProducts))
+	derived := map[string][]*LineageEdge{}
+	for _, product := range allProducts {
+		byID[product.ProductID] = product
+		if product.ParentID != "" {
+			derived[product.ParentID] = append(derived[product.ParentID], &LineageEdge{From: product.ParentID, To: product.ProductID, Relation: LineageSplit})
+		}
+		for _, componentID := range product.ComponentIDs {
+			derived[componentID] = append(derived[componentID], &LineageEdge{From: componentID, To: product.ProductID, Relation: LineageAssembly})
+		}
+	}
+	byID[root.ProductID] = root
+
+	sources := func(product *ProductEntity) []*LineageEdge {
+		var edges []*LineageEdge
+		if product.ParentID != "" {
+			edges = append(edges, &LineageEdge{From: product.ParentID, To: product.ProductID, Relation: LineageSplit})
+		}
+		for _, componentID := range product.ComponentIDs {
+			edges = append(edges, &LineageEdge{From: componentID, To: product.ProductID, Relation: LineageAssembly})
+		}
+		return edges
+	}
+
+	lineage := &ProductLineage{RootID: id, Nodes: []*ProductEntity{root}, Edges: []*LineageEdge{}}
+	visited := map[string]bool{id: true}
+	seenEdges := map[LineageEdge]bool{}
+
+	walk := func(next func(*ProductEntity) []*LineageEdge, otherEnd func(*LineageEdge) string) {
+		frontier := []*ProductEntity{root}
+		for depth := 0; len(frontier) > 0; depth++ {
+			if depth == maxLineageDepth {
+				// Only report truncation if the next generation actually has something to show
+				for _, product := range frontier {
+					for _, edge := range next(product) {
+						if !seenEdges[*edge] || !visited[otherEnd(edge)] {
+							lineage.Truncated = true
+						}
+					}
+				}
+				return
+			}
+			var nextFrontier []*ProductEntity
+			for _, product := range frontier {
+				for _, edge := range next(product) {
+					if !seenEdges[*edge] {
+						seenEdges[*edge] = true
+						lineage.Edges = append(lineage.Edges, edge)
+					}
+					relatedID := otherEnd(edge)
+					related, ok := byID[relatedID]
+					if !ok || visited[relatedID] {
+						continue
+					}
+					visited[relatedID] = true
+					lineage.Nodes = append(lineage.Nodes, related)
+					nextFrontier = append(nextFrontier, related)
+				}
+			}
+			frontier = nextFrontier
+		}
+	}
+	walk(sources, func(edge *LineageEdge) string { return edge.From })
+	walk(func(product *ProductEntity) []*LineageEdge { return derived[product.ProductID] }, func(edge *LineageEdge) string { return edge.To })
+
+	return lineage, nil
+}
+
+// GetProvenanceReport returns the current product together with its ownership chain, status log and sensor readings
+func (s *SupplyChainSmartContract) GetProvenanceReport(ctx contractapi.TransactionContextInterface, id string) (*ProvenanceReport, error) {
+	product, err := s.RetrieveProduct(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	ownershipChain, err := s.GetOwnershipChain(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	statusHistory, err := s.GetStatusHistory(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	sensorReadings, err := s.GetSensorReadings(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ProvenanceReport{
+		Product:        product,
+		OwnershipChain: ownershipChain,
+		StatusHistory:  statusHistory,
+		SensorReadings: sensorReadings,
+	}, nil
+}
+
+// QueryProductsByOwner returns the products currently held by the given owner using a CouchDB rich query
+func (s *SupplyChainSmartContract) QueryProductsByOwner(ctx contractapi.TransactionContextInterface, owner string) ([]*ProductEntity, error) {
+	queryString, err := buildSelectorQuery(map[string]interface{}{"current_owner": owner})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByOwnerWithPagination returns one page of the products held by an owner using a CouchDB rich query
+func (s *SupplyChainSmartContract) GetProductsByOwnerWithPagination(ctx contractapi.TransactionContextInterface, owner string, pageSize int32, bookmark string) (*PaginatedProductsResult, error) {
+	if pageSize <= 0 {
+		return nil, fmt.Errorf("page size must be greater than zero, got %d", pageSize)
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{"current_owner": owner})
+	if err != nil {
+		return nil, err
+	}
+
+	resultsIterator, responseMetadata, err := ctx.GetStub().GetQueryResultWithPagination(queryString, pageSize, bookmark)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+
+	products, err := collectProducts(resultsIterator)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PaginatedProductsResult{
+		Products:            products,
+		Bookmark:            responseMetadata.Bookmark,
+		FetchedRecordsCount: responseMetadata.FetchedRecordsCount,
+	}, nil
+}
+
+// GetProductsByManufacturer returns the products originally registered by the given manufacturer,
+// wherever they are now, using a CouchDB rich query
+func (s *SupplyChainSmartContract) GetProductsByManufacturer(ctx contractapi.TransactionContextInterface, manufacturer string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(manufacturer) == "" {
+		return nil, fmt.Errorf("manufacturer must not be empty")
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{"manufacturer": manufacturer})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByCreator returns the products registered by the given client identity using a CouchDB rich query
+func (s *SupplyChainSmartContract) GetProductsByCreator(ctx contractapi.TransactionContextInterface, creatorID string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(creatorID) == "" {
+		return nil, fmt.Errorf("creator ID must not be empty")
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{"created_by": creatorID})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByOwnerAndStatus returns the products held by an owner that are currently in the given status
+// using a CouchDB rich query
+func (s *SupplyChainSmartContract) GetProductsByOwnerAndStatus(ctx contractapi.TransactionContextInterface, owner, status string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(owner) == "" {
+		return nil, fmt.Errorf("owner must not be empty")
+	}
+	if err := s.validateQueryStatus(ctx, status); err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{
+		"current_owner":  owner,
+		"product_status": status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByPriceRange returns the products whose public list price lies within the inclusive range
+// using a CouchDB rich query; products without a list price are not matched
+func (s *SupplyChainSmartContract) GetProductsByPriceRange(ctx contractapi.TransactionContextInterface, min, max float64) ([]*ProductEntity, error) {
+	if min > max {
+		return nil, fmt.Errorf("minimum price %v is greater than maximum price %v", min, max)
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{
+		"list_price": map[string]interface{}{"$gte": min, "$lte": max},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByMetadata returns the products whose metadata holds the given key/value pair, e.g. every
+// product with lot_code=ABC123, using a CouchDB rich query on the nested metadata object
+func (s *SupplyChainSmartContract) GetProductsByMetadata(ctx contractapi.TransactionContextInterface, key, value string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(key) == "" {
+		return nil, fmt.Errorf("metadata key must not be empty")
+	}
+
+	// CouchDB reads dots in a field name as nesting, so literal dots in the key are escaped
+	field := "metadata." + strings.ReplaceAll(key, ".", `\.`)
+	queryString, err := buildSelectorQuery(map[string]interface{}{field: value})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// GetProductsByCreatorInRange returns the products a client identity registered within the inclusive
+// time window. The rich query only selects by creator; the window is applied to the parsed created_date,
+// because legacy records stored with an offset or as a plain date do not order correctly as strings.
+// Records whose created_date cannot be parsed are skipped.
+func (s *SupplyChainSmartContract) GetProductsByCreatorInRange(ctx contractapi.TransactionContextInterface, creatorID, startRFC3339, endRFC3339 string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(creatorID) == "" {
+		return nil, fmt.Errorf("creator ID must not be empty")
+	}
+	start, err := parseFlexibleTimestamp(startRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid start date %q: %v", startRFC3339, err)
+	}
+	end, err := parseFlexibleTimestamp(endRFC3339)
+	if err != nil {
+		return nil, fmt.Errorf("invalid end date %q: %v", endRFC3339, err)
+	}
+	if end.Before(start) {
+		return nil, fmt.Errorf("end date %s is before start date %s", endRFC3339, startRFC3339)
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{"created_by": creatorID})
+	if err != nil {
+		return nil, err
+	}
+	products, err := s.getQueryResultForQueryString(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	matches := []*ProductEntity{}
+	for _, product := range products {
+		created, err := parseFlexibleTimestamp(product.CreatedDate)
+		if err != nil {
+			continue
+		}
+		if !created.Before(start) && !created.After(end) {
+			matches = append(matches, product)
+		}
+	}
+	return matches, nil
+}
+
+// GetProductsByStatus returns all products currently in the given status using a CouchDB rich query
+func (s *SupplyChainSmartContract) GetProductsByStatus(ctx contractapi.TransactionContextInterface, status string) ([]*ProductEntity, error) {
+	if err := s.validateQueryStatus(ctx, status); err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{"product_status": status})
+	if err != nil {
+		return nil, err
+	}
+	results, err := s.getQueryResultForQueryString(ctx, queryString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Only exact matches are returned, even if the state database applies the selector loosely
+	products := []*ProductEntity{}
+	for _, product := range results {
+		if product.ProductStatus == status {
+			products = append(products, product)
+		}
+	}
+	return products, nil
+}
+
+// GetProductsByCategoryAndStatus returns the products in a category that are currently in the given status
+// using a CouchDB rich query. Deployments should ship a matching index, e.g.
+// {"index":{"fields":["product_category","product_status"]},"ddoc":"indexCategoryStatusDoc","name":"indexCategoryStatus","type":"json"}
+func (s *SupplyChainSmartContract) GetProductsByCategoryAndStatus(ctx contractapi.TransactionContextInterface, category, status string) ([]*ProductEntity, error) {
+	normalizedCategory, err := normalizeCategory(category)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.validateQueryStatus(ctx, status); err != nil {
+		return nil, err
+	}
+
+	queryString, err := buildSelectorQuery(map[string]interface{}{
+		"product_category": normalizedCategory,
+		"product_status":   status,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// SearchProducts filters products by any combination of owner, status and category; empty criteria are ignored.
+// The category is matched case-insensitively and must be one of the valid categories.
+func (s *SupplyChainSmartContract) SearchProducts(ctx contractapi.TransactionContextInterface, owner, status, category string) ([]*ProductEntity, error) {
+	if status != "" {
+		if err := s.validateQueryStatus(ctx, status); err != nil {
+			return nil, err
+		}
+	}
+	if category != "" {
+		normalizedCategory, err := normalizeCategory(category)
+		if err != nil {
+			return nil, err
+		}
+		category = normalizedCategory
+	}
+
+	selector := buildSearchSelector(owner, status, category)
+	if len(selector) == 0 {
+		return s.ListAllProducts(ctx)
+	}
+
+	queryString, err := buildSelectorQuery(selector)
+	if err != nil {
+		return nil, err
+	}
+	return s.getQueryResultForQueryString(ctx, queryString)
+}
+
+// SearchProductsByName returns the non-archived products whose name contains the substring, ignoring case.
+// It scans the world state so it works on LevelDB as well as CouchDB peers.
+func (s *SupplyChainSmartContract) SearchProductsByName(ctx contractapi.TransactionContextInterface, substring string) ([]*ProductEntity, error) {
+	if strings.TrimSpace(substring) == "" {
+		return nil, fmt.Errorf("search text must not be empty")
+	}
+
+	products, err := s.ListAllProducts(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	needle := strings.ToLower(substring)
+	matches := []*ProductEntity{}
+	for _, product := range products {
+		if strings.Contains(strings.ToLower(product.ProductName), needle) {
+			matches = append(matches, product)
+		}
+	}
+	return matches, nil
+}
+
+// buildSearchSelector returns the CouchDB selector conditions for the non-empty search criteria
+func buildSearchSelector(owner, status, category string) map[string]interface{} {
+	selector := map[string]interface{}{}
+	if owner != "" {
+		selector["current_owner"] = owner
+	}
+	if status != "" {
+		selector["product_status"] = status
+	}
+	if category != "" {
+		selector["product_category"] = category
+	}
+	return selector
+}
+
+// buildSelectorQuery marshals the given field conditions into a CouchDB selector query string
+func buildSelectorQuery(selector map[string]interface{}) (string, error) {
+	queryBytes, err := json.Marshal(map[string]interface{}{"selector": selector})
+	if err != nil {
+		return "", fmt.Errorf("failed to build query: %v", err)
+	}
+	return string(queryBytes), nil
+}
+
+// getQueryResultForQueryString executes a rich query and parses the matching products
+func (s *SupplyChainSmartContract) getQueryResultForQueryString(ctx contractapi.TransactionContextInterface, queryString string) ([]*ProductEntity, error) {
+	resultsIterator, err := ctx.GetStub().GetQueryResult(queryString)
+	if err != nil {
+		return nil, fmt.Errorf("error executing query: %v", err)
+	}
+
+	return collectProducts(resultsIterator)
+}
+
+// collectProducts drains a query iterator into products and always closes it; a failed close is
+// reported unless an earlier error is already being returned. The slice is never nil, so an empty
+// result marshals to [] rather than null.
+func collectProducts(resultsIterator shim.StateQueryIteratorInterface) (products []*ProductEntity, err error) {
+	defer closeIterator(resultsIterator, &err)
+
+	products = []*ProductEntity{}
+	for resultsIterator.HasNext() {
+		queryResponse, err := resultsIterator.Next()
+		if err != nil {
+			return nil, err
+		}
+		// Peers skip composite keys in range scans, but not every state implementation does
+		if isCompositeKey(queryResponse.Key) {
+			continue
+		}
+
+		var product ProductEntity
+		if err := json.Unmarshal(queryResponse.Value, &product); err != nil {
+			return nil, fmt.Errorf("failed to parse product %s: %v", queryResponse.Key, err)
+		}
+		products = append(products, &product)
+	}
+
+	return products, nil
+}
+
+// closeIterator closes a ledger iterator and stores a close failure in err if no other error is pending
+func closeIterator(iterator interface{ Close() error }, err *error) {
+	if closeErr := iterator.Close(); closeErr != nil && *err == nil {
+		*err = fmt.Errorf("failed to close iterator: %v", closeErr)
+	}
 }
 
 func main() {
@@ -0,0 +1,1405 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hyperledger/fabric-chaincode-go/pkg/cid"
+	"github.com/hyperledger/fabric-chaincode-go/pkg/statebased"
+	"github.com/hyperledger/fabric-chaincode-go/shim"
+	"github.com/hyperledger/fabric-contract-api-go/contractapi"
+	"github.com/hyperledger/fabric-protos-go/ledger/queryresult"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+const (
+	testMSPID    = "Org1MSP"
+	testClientID = "x509::CN=tester,OU=client::CN=ca.org1.example.com"
+	testTxID     = "tx-1"
+)
+
+var testTxTime = time.Date(2024, time.January, 15, 10, 0, 0, 0, time.UTC)
+
+// MockTransactionContext is a testify mock of contractapi.TransactionContextInterface
+type MockTransactionContext struct {
+	mock.Mock
+}
+
+func (m *MockTransactionContext) GetStub() shim.ChaincodeStubInterface {
+	args := m.Called()
+	return args.Get(0).(shim.ChaincodeStubInterface)
+}
+
+func (m *MockTransactionContext) GetClientIdentity() cid.ClientIdentity {
+	args := m.Called()
+	return args.Get(0).(cid.ClientIdentity)
+}
+
+// MockClientIdentity is a testify mock of cid.ClientIdentity
+type MockClientIdentity struct {
+	mock.Mock
+}
+
+func (m *MockClientIdentity) GetID() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockClientIdentity) GetMSPID() (string, error) {
+	args := m.Called()
+	return args.String(0), args.Error(1)
+}
+
+func (m *MockClientIdentity) GetAttributeValue(attrName string) (string, bool, error) {
+	args := m.Called(attrName)
+	return args.String(0), args.Bool(1), args.Error(2)
+}
+
+func (m *MockClientIdentity) AssertAttributeValue(attrName, attrValue string) error {
+	args := m.Called(attrName, attrValue)
+	return args.Error(0)
+}
+
+func (m *MockClientIdentity) GetX509Certificate() (*x509.Certificate, error) {
+	args := m.Called()
+	cert, _ := args.Get(0).(*x509.Certificate)
+	return cert, args.Error(1)
+}
+
+// MockStub is a testify mock of shim.ChaincodeStubInterface. Only the methods the contract calls in
+// these tests are mocked; composite keys are built with the real shim helpers since they do not touch
+// the ledger. Calling any other method panics on the nil embedded interface.
+type MockStub struct {
+	shim.ChaincodeStubInterface
+	mock.Mock
+}
+
+func (m *MockStub) GetState(key string) ([]byte, error) {
+	args := m.Called(key)
+	value, _ := args.Get(0).([]byte)
+	return value, args.Error(1)
+}
+
+func (m *MockStub) PutState(key string, value []byte) error {
+	args := m.Called(key, value)
+	return args.Error(0)
+}
+
+func (m *MockStub) GetTxID() string {
+	args := m.Called()
+	return args.String(0)
+}
+
+func (m *MockStub) GetTxTimestamp() (*timestamppb.Timestamp, error) {
+	args := m.Called()
+	timestamp, _ := args.Get(0).(*timestamppb.Timestamp)
+	return timestamp, args.Error(1)
+}
+
+func (m *MockStub) SetEvent(name string, payload []byte) error {
+	args := m.Called(name, payload)
+	return args.Error(0)
+}
+
+func (m *MockStub) GetStateByRange(startKey, endKey string) (shim.StateQueryIteratorInterface, error) {
+	args := m.Called(startKey, endKey)
+	iterator, _ := args.Get(0).(shim.StateQueryIteratorInterface)
+	return iterator, args.Error(1)
+}
+
+func (m *MockStub) GetStateByPartialCompositeKey(objectType string, keys []string) (shim.StateQueryIteratorInterface, error) {
+	args := m.Called(objectType, keys)
+	iterator, _ := args.Get(0).(shim.StateQueryIteratorInterface)
+	return iterator, args.Error(1)
+}
+
+func (m *MockStub) GetQueryResult(query string) (shim.StateQueryIteratorInterface, error) {
+	args := m.Called(query)
+	iterator, _ := args.Get(0).(shim.StateQueryIteratorInterface)
+	return iterator, args.Error(1)
+}
+
+func (m *MockStub) GetHistoryForKey(key string) (shim.HistoryQueryIteratorInterface, error) {
+	args := m.Called(key)
+	iterator, _ := args.Get(0).(shim.HistoryQueryIteratorInterface)
+	return iterator, args.Error(1)
+}
+
+func (m *MockStub) DelState(key string) error {
+	args := m.Called(key)
+	return args.Error(0)
+}
+
+func (m *MockStub) SetStateValidationParameter(key string, ep []byte) error {
+	args := m.Called(key, ep)
+	return args.Error(0)
+}
+
+func (m *MockStub) CreateCompositeKey(objectType string, attributes []string) (string, error) {
+	return shim.CreateCompositeKey(objectType, attributes)
+}
+
+// MockStateQueryIterator replays a fixed list of key/value results
+type MockStateQueryIterator struct {
+	results []*queryresult.KV
+	closed  bool
+}
+
+func (it *MockStateQueryIterator) HasNext() bool {
+	return len(it.results) > 0
+}
+
+func (it *MockStateQueryIterator) Next() (*queryresult.KV, error) {
+	next := it.results[0]
+	it.results = it.results[1:]
+	return next, nil
+}
+
+func (it *MockStateQueryIterator) Close() error {
+	it.closed = true
+	return nil
+}
+
+// MockHistoryQueryIterator replays a fixed list of key modifications
+type MockHistoryQueryIterator struct {
+	results []*queryresult.KeyModification
+}
+
+func (it *MockHistoryQueryIterator) HasNext() bool {
+	return len(it.results) > 0
+}
+
+func (it *MockHistoryQueryIterator) Next() (*queryresult.KeyModification, error) {
+	next := it.results[0]
+	it.results = it.results[1:]
+	return next, nil
+}
+
+func (it *MockHistoryQueryIterator) Close() error {
+	return nil
+}
+
+// newProductIterator returns an iterator over the given products, keyed by product ID
+func newProductIterator(t *testing.T, products ...*ProductEntity) *MockStateQueryIterator {
+	t.Helper()
+	iterator := &MockStateQueryIterator{}
+	for _, product := range products {
+		productBytes, err := json.Marshal(product)
+		require.NoError(t, err)
+		iterator.results = append(iterator.results, &queryresult.KV{Key: product.ProductID, Value: productBytes})
+	}
+	return iterator
+}
+
+// newMockContext wires a mocked stub and an Org1MSP client identity into a mocked transaction context
+func newMockContext() (*MockTransactionContext, *MockStub) {
+	stub := new(MockStub)
+	stub.On("GetTxTimestamp").Return(timestamppb.New(testTxTime), nil).Maybe()
+	stub.On("GetTxID").Return(testTxID).Maybe()
+
+	identity := new(MockClientIdentity)
+	identity.On("GetMSPID").Return(testMSPID, nil).Maybe()
+	identity.On("GetID").Return(testClientID, nil).Maybe()
+	identity.On("GetAttributeValue", mock.Anything).Return("", false, nil).Maybe()
+	identity.On("AssertAttributeValue", adminAttribute, "true").Return(errors.New("attribute not found")).Maybe()
+
+	ctx := new(MockTransactionContext)
+	ctx.On("GetStub").Return(stub).Maybe()
+	ctx.On("GetClientIdentity").Return(identity).Maybe()
+
+	return ctx, stub
+}
+
+// useIdentity makes the mocked context act for a client of the given organization
+func useIdentity(ctx *MockTransactionContext, mspID string) {
+	setIdentity(ctx, mspID, "", false)
+}
+
+// useOwnerIdentity makes the mocked context act for a client of the given organization whose certificate
+// carries the given "owner" attribute
+func useOwnerIdentity(ctx *MockTransactionContext, mspID, owner string) {
+	setIdentity(ctx, mspID, owner, false)
+}
+
+// useAdminIdentity makes the mocked context act for an admin organization client with the admin attribute
+func useAdminIdentity(ctx *MockTransactionContext) {
+	setIdentity(ctx, adminMSPID, "", true)
+}
+
+// setIdentity replaces the mocked client identity; an empty owner leaves the "owner" attribute out
+func setIdentity(ctx *MockTransactionContext, mspID, owner string, admin bool) {
+	identity := new(MockClientIdentity)
+	identity.On("GetMSPID").Return(mspID, nil).Maybe()
+	identity.On("GetID").Return("x509::CN=tester::CN=ca."+mspID, nil).Maybe()
+	identity.On("GetAttributeValue", ownerAttribute).Return(owner, owner != "", nil).Maybe()
+	identity.On("GetAttributeValue", mock.Anything).Return("", false, nil).Maybe()
+	if admin {
+		identity.On("AssertAttributeValue", adminAttribute, "true").Return(nil).Maybe()
+	} else {
+		identity.On("AssertAttributeValue", adminAttribute, "true").Return(errors.New("attribute not found")).Maybe()
+	}
+
+	calls := ctx.ExpectedCalls[:0]
+	for _, call := range ctx.ExpectedCalls {
+		if call.Method != "GetClientIdentity" {
+			calls = append(calls, call)
+		}
+	}
+	ctx.ExpectedCalls = calls
+	ctx.On("GetClientIdentity").Return(identity).Maybe()
+}
+
+// setTxTime replaces the mocked transaction timestamp
+func setTxTime(stub *MockStub, txTime time.Time) {
+	calls := stub.ExpectedCalls[:0]
+	for _, call := range stub.ExpectedCalls {
+		if call.Method != "GetTxTimestamp" {
+			calls = append(calls, call)
+		}
+	}
+	stub.ExpectedCalls = calls
+	stub.On("GetTxTimestamp").Return(timestamppb.New(txTime), nil).Maybe()
+}
+
+// expectNoConfig makes every internal composite-key read (configuration, indexes) come back empty
+func expectNoConfig(stub *MockStub) {
+	stub.On("GetState", mock.MatchedBy(isCompositeKey)).Return(nil, nil).Maybe()
+}
+
+// storedProduct returns the JSON of a product registered by the test organization
+func storedProduct(t *testing.T, id string) []byte {
+	t.Helper()
+	return storedProductOwnedBy(t, id, testMSPID)
+}
+
+// storedProductOwnedBy returns the JSON of a product owned by the given organization
+func storedProductOwnedBy(t *testing.T, id, ownerOrg string) []byte {
+	t.Helper()
+	product := ProductEntity{
+		ProductID:       id,
+		ProductName:     "Laptop",
+		ProductStatus:   StatusManufactured,
+		CurrentOwner:    "TechCorp",
+		CurrentOwnerOrg: ownerOrg,
+		Quantity:        1,
+		CreatedDate:     "2024-01-01T00:00:00Z",
+		UpdatedDate:     "2024-01-01T00:00:00Z",
+		CreatedBy:       testClientID,
+		Manufacturer:    "TechCorp",
+		Version:         1,
+	}
+	productBytes, err := json.Marshal(product)
+	require.NoError(t, err)
+	return productBytes
+}
+
+// putProduct captures the product written under id
+func putProduct(t *testing.T, stub *MockStub, id string) *ProductEntity {
+	t.Helper()
+	for _, call := range stub.Calls {
+		if call.Method == "PutState" && call.Arguments.String(0) == id {
+			var product ProductEntity
+			require.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &product))
+			return &product
+		}
+	}
+	t.Fatalf("product %s was not written", id)
+	return nil
+}
+
+func TestRegisterProduct(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(nil, nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetEvent", ProductRegisteredEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	err := contract.RegisterProduct(ctx, "prod-1", "Laptop", "TechCorp", "Business laptop", "electronics", 5, "")
+	require.NoError(t, err)
+
+	product := putProduct(t, stub, "prod-1")
+	require.Equal(t, "Laptop", product.ProductName)
+	require.Equal(t, StatusManufactured, product.ProductStatus)
+	require.Equal(t, "Electronics", product.ProductCategory)
+	require.Equal(t, testMSPID, product.CurrentOwnerOrg)
+	require.Equal(t, testClientID, product.CreatedBy)
+	require.Equal(t, testTxTime.Format(time.RFC3339), product.CreatedDate)
+	require.Equal(t, 1, product.Version)
+	stub.AssertCalled(t, "SetEvent", ProductRegisteredEvent, mock.Anything)
+}
+
+func TestRegisterProductDuplicate(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	err := contract.RegisterProduct(ctx, "prod-1", "Laptop", "TechCorp", "", "", 1, "")
+	require.ErrorIs(t, err, ErrProductExists)
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestRetrieveProduct(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	product, err := contract.RetrieveProduct(ctx, "prod-1")
+	require.NoError(t, err)
+	require.Equal(t, "prod-1", product.ProductID)
+	require.Equal(t, "TechCorp", product.CurrentOwner)
+}
+
+func TestRetrieveProductNotFound(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "missing").Return(nil, nil)
+
+	contract := new(SupplyChainSmartContract)
+	product, err := contract.RetrieveProduct(ctx, "missing")
+	require.ErrorIs(t, err, ErrProductNotFound)
+	require.Nil(t, product)
+}
+
+func TestRetrieveProductLedgerError(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(nil, errors.New("peer unavailable"))
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.RetrieveProduct(ctx, "prod-1")
+	require.ErrorContains(t, err, "peer unavailable")
+}
+
+func TestModifyProduct(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	changed, err := contract.ModifyProduct(ctx, "prod-1", StatusInTransit, "", "Refurbished", "")
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	product := putProduct(t, stub, "prod-1")
+	require.Equal(t, StatusInTransit, product.ProductStatus)
+	require.Equal(t, "Refurbished", product.ProductDescription)
+	require.Equal(t, testTxTime.Format(time.RFC3339), product.UpdatedDate)
+	require.Equal(t, 2, product.Version)
+}
+
+func TestModifyProductRejectsOtherOrg(t *testing.T) {
+	ctx, stub := newMockContext()
+	useIdentity(ctx, "Org2MSP")
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.ModifyProduct(ctx, "prod-1", StatusInTransit, "", "", "")
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestContractMetadata(t *testing.T) {
+	_, err := contractapi.NewChaincode(new(SupplyChainSmartContract))
+	require.NoError(t, err)
+}
+
+func TestModifyProductMalformedState(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return([]byte(`{"product_id": "prod-1", "quantity": "five"`), nil)
+
+	contract := new(SupplyChainSmartContract)
+	changed, err := contract.ModifyProduct(ctx, "prod-1", StatusInTransit, "", "", "")
+	require.ErrorContains(t, err, "failed to parse stored product prod-1")
+	require.False(t, changed)
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestGetProductsByStatus(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	iterator := newProductIterator(t,
+		&ProductEntity{ProductID: "prod-1", ProductStatus: StatusInTransit},
+		&ProductEntity{ProductID: "prod-2", ProductStatus: StatusDelivered},
+		&ProductEntity{ProductID: "prod-3", ProductStatus: StatusInTransit},
+		&ProductEntity{ProductID: "prod-4", ProductStatus: StatusManufactured},
+	)
+	stub.On("GetQueryResult", `{"selector":{"product_status":"InTransit"}}`).Return(iterator, nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.GetProductsByStatus(ctx, StatusInTransit)
+	require.NoError(t, err)
+	require.Len(t, products, 2)
+	require.Equal(t, "prod-1", products[0].ProductID)
+	require.Equal(t, "prod-3", products[1].ProductID)
+	require.True(t, iterator.closed)
+}
+
+func TestGetProductsByStatusNoMatches(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetQueryResult", mock.Anything).Return(newProductIterator(t), nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.GetProductsByStatus(ctx, StatusSold)
+	require.NoError(t, err)
+	require.NotNil(t, products)
+	require.Empty(t, products)
+}
+
+func TestGetProductsByStatusRejectsUnknownStatus(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.GetProductsByStatus(ctx, "Teleported")
+	require.ErrorContains(t, err, "invalid product status")
+	stub.AssertNotCalled(t, "GetQueryResult", mock.Anything)
+}
+
+func TestSentinelErrorsMatchAfterWrapping(t *testing.T) {
+	contract := new(SupplyChainSmartContract)
+
+	tests := []struct {
+		name     string
+		stored   []byte
+		call     func(ctx *MockTransactionContext) error
+		sentinel error
+	}{
+		{
+			name: "RetrieveProduct not found",
+			call: func(ctx *MockTransactionContext) error {
+				_, err := contract.RetrieveProduct(ctx, "prod-1")
+				return err
+			},
+			sentinel: ErrProductNotFound,
+		},
+		{
+			name: "ModifyProduct not found",
+			call: func(ctx *MockTransactionContext) error {
+				_, err := contract.ModifyProduct(ctx, "prod-1", StatusInTransit, "", "", "")
+				return err
+			},
+			sentinel: ErrProductNotFound,
+		},
+		{
+			name:   "RegisterProduct exists",
+			stored: storedProduct(t, "prod-1"),
+			call: func(ctx *MockTransactionContext) error {
+				return contract.RegisterProduct(ctx, "prod-1", "Laptop", "TechCorp", "", "", 1, "")
+			},
+			sentinel: ErrProductExists,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			expectNoConfig(stub)
+			stub.On("GetState", "prod-1").Return(tt.stored, nil)
+
+			err := tt.call(ctx)
+			require.Error(t, err)
+			require.True(t, errors.Is(err, tt.sentinel), "expected %v to wrap %v", err, tt.sentinel)
+			require.Contains(t, err.Error(), "prod-1")
+			require.NotEqual(t, tt.sentinel, err, "the sentinel should be wrapped with the product ID")
+		})
+	}
+}
+
+func TestIsValidProductID(t *testing.T) {
+	valid := []string{"prod-1", "PROD001", "a", "batch-2024-01-lot-7", "123"}
+	for _, id := range valid {
+		require.True(t, isValidProductID(id), "expected %q to be valid", id)
+	}
+
+	invalid := []string{"", "prod 1", "prod/1", "prod\x001", "\x00", "prod_1", "prod.1", "prod~1", "prod:1", "prödukt", " prod-1"}
+	for _, id := range invalid {
+		require.False(t, isValidProductID(id), "expected %q to be invalid", id)
+	}
+}
+
+func TestRegisterProductRejectsInvalidID(t *testing.T) {
+	for _, id := range []string{"prod 1", "prod/1", "prod\x001"} {
+		ctx, stub := newMockContext()
+		expectNoConfig(stub)
+
+		contract := new(SupplyChainSmartContract)
+		err := contract.RegisterProduct(ctx, id, "Laptop", "TechCorp", "", "", 1, "")
+		require.ErrorContains(t, err, "invalid product ID")
+		stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	}
+}
+
+func TestBuildSearchSelector(t *testing.T) {
+	tests := []struct {
+		owner, status, category string
+		want                    string
+	}{
+		{"", "", "", `{"selector":{}}`},
+		{"TechCorp", "", "", `{"selector":{"current_owner":"TechCorp"}}`},
+		{"", StatusInTransit, "", `{"selector":{"product_status":"InTransit"}}`},
+		{"", "", "Electronics", `{"selector":{"product_category":"Electronics"}}`},
+		{"TechCorp", StatusInTransit, "", `{"selector":{"current_owner":"TechCorp","product_status":"InTransit"}}`},
+		{"TechCorp", "", "Electronics", `{"selector":{"current_owner":"TechCorp","product_category":"Electronics"}}`},
+		{"", StatusSold, "Food", `{"selector":{"product_category":"Food","product_status":"Sold"}}`},
+		{"TechCorp", StatusDelivered, "Electronics", `{"selector":{"current_owner":"TechCorp","product_category":"Electronics","product_status":"Delivered"}}`},
+		{`Quote "Corp"`, "", "", `{"selector":{"current_owner":"Quote \"Corp\""}}`},
+	}
+
+	for _, tt := range tests {
+		query, err := buildSelectorQuery(buildSearchSelector(tt.owner, tt.status, tt.category))
+		require.NoError(t, err)
+		require.JSONEq(t, tt.want, query)
+	}
+}
+
+func TestSearchProductsSendsSelector(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetQueryResult", `{"selector":{"current_owner":"TechCorp","product_status":"InTransit"}}`).
+		Return(newProductIterator(t, &ProductEntity{ProductID: "prod-1", CurrentOwner: "TechCorp", ProductStatus: StatusInTransit}), nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.SearchProducts(ctx, "TechCorp", StatusInTransit, "")
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	require.Equal(t, "prod-1", products[0].ProductID)
+}
+
+func TestSearchProductsNormalizesCategory(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetQueryResult", `{"selector":{"product_category":"Electronics"}}`).Return(newProductIterator(t), nil)
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.SearchProducts(ctx, "", "", " electronics")
+	require.NoError(t, err)
+
+	_, err = contract.SearchProducts(ctx, "", "", "Gadgets")
+	require.ErrorContains(t, err, `invalid category "Gadgets"`)
+}
+
+func TestGetProductsByCategoryNormalizesCategory(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetStateByPartialCompositeKey", categoryIndex, []string{"Electronics"}).Return(newProductIterator(t), nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.GetProductsByCategory(ctx, "ELECTRONICS")
+	require.NoError(t, err)
+	require.Empty(t, products)
+
+	_, err = contract.GetProductsByCategory(ctx, "Gadgets")
+	require.ErrorContains(t, err, `invalid category "Gadgets"`)
+}
+
+func TestSearchProductsWithoutCriteriaListsAll(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetStateByRange", "", "").Return(newProductIterator(t, &ProductEntity{ProductID: "prod-1"}), nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.SearchProducts(ctx, "", "", "")
+	require.NoError(t, err)
+	require.Len(t, products, 1)
+	stub.AssertNotCalled(t, "GetQueryResult", mock.Anything)
+}
+
+func TestValidateProductInputLengthLimits(t *testing.T) {
+	fields := []struct {
+		name  string
+		limit int
+		input func(value string) []string
+	}{
+		{"product ID", maxProductIDLength, func(v string) []string { return []string{v, "name", "owner", "", ""} }},
+		{"product name", maxNameLength, func(v string) []string { return []string{"id", v, "owner", "", ""} }},
+		{"product owner", maxOwnerLength, func(v string) []string { return []string{"id", "name", v, "", ""} }},
+		{"product description", maxDescriptionLength, func(v string) []string { return []string{"id", "name", "owner", v, ""} }},
+		{"product category", maxCategoryLength, func(v string) []string { return []string{"id", "name", "owner", "", v} }},
+	}
+
+	for _, field := range fields {
+		t.Run(field.name, func(t *testing.T) {
+			// Limits count characters, not bytes
+			atLimit := field.input(strings.Repeat("é", field.limit))
+			require.NoError(t, validateProductInput(atLimit[0], atLimit[1], atLimit[2], atLimit[3], atLimit[4]))
+
+			overLimit := field.input(strings.Repeat("é", field.limit+1))
+			err := validateProductInput(overLimit[0], overLimit[1], overLimit[2], overLimit[3], overLimit[4])
+			require.EqualError(t, err, fmt.Sprintf("%s exceeds the maximum length of %d characters", field.name, field.limit))
+		})
+	}
+}
+
+func TestModifyProductRejectsOversizedDescription(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.ModifyProduct(ctx, "prod-1", "", "", strings.Repeat("x", maxDescriptionLength+1), "")
+	require.ErrorContains(t, err, "product description exceeds the maximum length of 4096 characters")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestProductEntityOmitsEmptyOptionalFields(t *testing.T) {
+	productBytes, err := json.Marshal(ProductEntity{ProductID: "prod-1", ProductName: "Laptop"})
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(productBytes, &fields))
+
+	required := []string{
+		"product_id", "product_name", "product_status", "current_owner", "current_owner_org", "quantity",
+		"version", "cold_chain_breached", "archived", "flagged", "created_by", "last_modified_by", "created_date", "updated_date",
+	}
+	for _, key := range required {
+		require.Contains(t, fields, key)
+	}
+
+	optional := []string{
+		"product_description", "product_category", "pending_owner", "pending_owner_org", "pending_swap_with",
+		"current_location", "recall_reason",
+		"recalled_date", "cold_chain", "certifications", "expiry_date", "metadata", "parent_id", "component_ids",
+		"document_hashes", "manufacturer", "last_tx_id", "flag_reason", "list_price",
+	}
+	for _, key := range optional {
+		require.NotContains(t, fields, key)
+	}
+	require.Len(t, fields, len(required))
+}
+
+func TestProductEntityKeepsSetOptionalFields(t *testing.T) {
+	productBytes, err := json.Marshal(ProductEntity{ProductID: "prod-1", ProductDescription: "Business laptop", ProductCategory: "Electronics"})
+	require.NoError(t, err)
+
+	var fields map[string]interface{}
+	require.NoError(t, json.Unmarshal(productBytes, &fields))
+	require.Equal(t, "Business laptop", fields["product_description"])
+	require.Equal(t, "Electronics", fields["product_category"])
+}
+
+func TestModifyProductNoOp(t *testing.T) {
+	tests := []struct {
+		name                                 string
+		status, owner, description, category string
+	}{
+		{"all fields blank", "", "", "", ""},
+		{"same values", StatusManufactured, "TechCorp", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			expectNoConfig(stub)
+			stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+			contract := new(SupplyChainSmartContract)
+			changed, err := contract.ModifyProduct(ctx, "prod-1", tt.status, tt.owner, tt.description, tt.category)
+			require.NoError(t, err)
+			require.False(t, changed)
+			stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+			stub.AssertNotCalled(t, "GetTxTimestamp")
+		})
+	}
+}
+
+func TestModifyProductRealChange(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	changed, err := contract.ModifyProduct(ctx, "prod-1", StatusManufactured, "", "", "food")
+	require.NoError(t, err)
+	require.True(t, changed)
+	require.Equal(t, "Food", putProduct(t, stub, "prod-1").ProductCategory)
+}
+
+func TestValidateTimeline(t *testing.T) {
+	tests := []struct {
+		name, created, updated string
+		wantErr                bool
+	}{
+		{"updated after created", "2024-01-01T00:00:00Z", "2024-01-02T00:00:00Z", false},
+		{"same instant", "2024-01-01T00:00:00Z", "2024-01-01T00:00:00Z", false},
+		{"same instant in another zone", "2024-01-01T00:00:00Z", "2024-01-01T01:00:00+01:00", false},
+		{"inverted", "2024-01-02T00:00:00Z", "2024-01-01T23:59:59Z", true},
+		{"inverted by zone offset", "2024-01-01T12:00:00Z", "2024-01-01T12:30:00+01:00", true},
+		{"legacy record without dates", "", "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := validateTimeline(&ProductEntity{ProductID: "prod-1", CreatedDate: tt.created, UpdatedDate: tt.updated})
+			if tt.wantErr {
+				require.ErrorContains(t, err, "before it was created")
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestModifyProductRejectsUpdateBeforeCreation(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	// The stored product was created on 2024-01-01; the transaction claims an earlier time
+	setTxTime(stub, time.Date(2023, time.December, 31, 0, 0, 0, 0, time.UTC))
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.ModifyProduct(ctx, "prod-1", StatusInTransit, "", "", "")
+	require.ErrorContains(t, err, "before it was created")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestRegisterProductRejectsReservedPrefixes(t *testing.T) {
+	for _, prefix := range reservedKeyPrefixes {
+		id := prefix + "prod-1"
+		ctx, stub := newMockContext()
+		expectNoConfig(stub)
+
+		contract := new(SupplyChainSmartContract)
+		err := contract.RegisterProduct(ctx, id, "Laptop", "TechCorp", "", "", 1, "")
+		require.ErrorContains(t, err, "is reserved for internal records", "prefix %q", prefix)
+		stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	}
+}
+
+func TestReservedKeyPrefixAllowsNormalIDs(t *testing.T) {
+	for _, id := range []string{"prod-1", "counter-1", "category", "readings-2024", "config", "bundle1", "idempotency"} {
+		_, reserved := reservedKeyPrefix(id)
+		require.False(t, reserved, "expected %q not to be reserved", id)
+	}
+
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "counter-1").Return(nil, nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetEvent", ProductRegisteredEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	require.NoError(t, contract.RegisterProduct(ctx, "counter-1", "Laptop", "TechCorp", "", "", 1, ""))
+}
+
+func TestListAllProductsSkipsCompositeKeys(t *testing.T) {
+	categoryKey, err := shim.CreateCompositeKey(categoryIndex, []string{"Electronics", "prod-a"})
+	require.NoError(t, err)
+	configKey, err := shim.CreateCompositeKey(configIndex, []string{allowedStatusesConfig})
+	require.NoError(t, err)
+
+	iterator := newProductIterator(t,
+		&ProductEntity{ProductID: "prod-a"},
+		&ProductEntity{ProductID: "prod-b"},
+		&ProductEntity{ProductID: "prod-c", Archived: true},
+		&ProductEntity{ProductID: "prod-d"},
+	)
+	// Composite keys sort before plain keys; one is also placed mid-stream in case a state database interleaves them
+	iterator.results = append([]*queryresult.KV{{Key: categoryKey, Value: []byte("\x00")}}, iterator.results...)
+	iterator.results = append(iterator.results[:3], append([]*queryresult.KV{{Key: configKey, Value: []byte(`["Manufactured"]`)}}, iterator.results[3:]...)...)
+
+	ctx, stub := newMockContext()
+	stub.On("GetStateByRange", "", "").Return(iterator, nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.ListAllProducts(ctx)
+	require.NoError(t, err)
+
+	ids := make([]string, 0, len(products))
+	for _, product := range products {
+		ids = append(ids, product.ProductID)
+	}
+	require.Equal(t, []string{"prod-a", "prod-b", "prod-d"}, ids)
+	require.True(t, iterator.closed)
+}
+
+func TestSaveProductRejectsManufacturerChange(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	// Bypass the public methods and hand saveProduct a record whose manufacturer was rewritten
+	var crafted ProductEntity
+	require.NoError(t, json.Unmarshal(storedProduct(t, "prod-1"), &crafted))
+	crafted.Manufacturer = "CounterfeitCorp"
+	crafted.UpdatedDate = testTxTime.Format(time.RFC3339)
+
+	contract := new(SupplyChainSmartContract)
+	err := contract.saveProduct(ctx, &crafted)
+	require.ErrorIs(t, err, ErrImmutableField)
+	require.ErrorContains(t, err, "manufacturer")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestCheckImmutableFields(t *testing.T) {
+	stored := &ProductEntity{ProductID: "prod-1", CreatedDate: "2024-01-01T00:00:00Z", CreatedBy: testClientID, Manufacturer: "TechCorp", ParentID: "batch-1"}
+
+	changes := map[string]func(p *ProductEntity){
+		"created_date": func(p *ProductEntity) { p.CreatedDate = "2023-01-01T00:00:00Z" },
+		"created_by":   func(p *ProductEntity) { p.CreatedBy = "x509::CN=someone-else" },
+		"manufacturer": func(p *ProductEntity) { p.Manufacturer = "" },
+		"parent_id":    func(p *ProductEntity) { p.ParentID = "batch-2" },
+	}
+	for field, change := range changes {
+		updated := *stored
+		change(&updated)
+		err := checkImmutableFields(stored, &updated)
+		require.ErrorIs(t, err, ErrImmutableField, field)
+		require.ErrorContains(t, err, field)
+	}
+
+	// Mutable fields may change, and a write-once field left empty by a legacy record may be filled
+	updated := *stored
+	updated.ProductDescription = "Refurbished"
+	require.NoError(t, checkImmutableFields(stored, &updated))
+
+	legacy := &ProductEntity{ProductID: "prod-1", CreatedDate: stored.CreatedDate}
+	require.NoError(t, checkImmutableFields(legacy, stored))
+}
+
+func TestParseFlexibleTimestamp(t *testing.T) {
+	tests := []struct {
+		value string
+		want  time.Time
+	}{
+		{"2024-03-05T14:30:00Z", time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)},
+		{"2024-03-05T15:30:00+01:00", time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)},
+		{"2024-03-05T14:30:00.123456789Z", time.Date(2024, time.March, 5, 14, 30, 0, 123456789, time.UTC)},
+		{"2024-03-05T14:30:00", time.Date(2024, time.March, 5, 14, 30, 0, 0, time.UTC)},
+		{"2024-03-05", time.Date(2024, time.March, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		parsed, err := parseFlexibleTimestamp(tt.value)
+		require.NoError(t, err, tt.value)
+		require.True(t, tt.want.Equal(parsed), "%s parsed as %s", tt.value, parsed)
+	}
+
+	for _, value := range []string{"", "05/03/2024", "2024-13-01", "March 5, 2024", "1709649000"} {
+		_, err := parseFlexibleTimestamp(value)
+		require.ErrorContains(t, err, "unrecognized timestamp", value)
+	}
+}
+
+func TestIsExpiredAcceptsLegacyDates(t *testing.T) {
+	now := time.Date(2024, time.March, 5, 12, 0, 0, 0, time.UTC)
+	for _, expiry := range []string{"2024-03-04T12:00:00Z", "2024-03-04T12:00:00.5Z", "2024-03-04T12:00:00", "2024-03-04"} {
+		expired, err := isExpired(&ProductEntity{ProductID: "prod-1", ExpiryDate: expiry}, now)
+		require.NoError(t, err, expiry)
+		require.True(t, expired, expiry)
+	}
+
+	expired, err := isExpired(&ProductEntity{ProductID: "prod-1", ExpiryDate: "2024-03-06"}, now)
+	require.NoError(t, err)
+	require.False(t, expired)
+
+	_, err = isExpired(&ProductEntity{ProductID: "prod-1", ExpiryDate: "next tuesday"}, now)
+	require.ErrorContains(t, err, "invalid expiry date")
+}
+
+func TestEmptyLedgerListsMarshalToEmptyArray(t *testing.T) {
+	contract := new(SupplyChainSmartContract)
+	queries := map[string]func(ctx *MockTransactionContext) (interface{}, error){
+		"ListAllProducts": func(ctx *MockTransactionContext) (interface{}, error) {
+			return contract.ListAllProducts(ctx)
+		},
+		"ListAllProductsIncludingArchived": func(ctx *MockTransactionContext) (interface{}, error) {
+			return contract.ListAllProductsIncludingArchived(ctx)
+		},
+		"GetProductsByStatus": func(ctx *MockTransactionContext) (interface{}, error) {
+			return contract.GetProductsByStatus(ctx, StatusInTransit)
+		},
+		"ListFlaggedProducts": func(ctx *MockTransactionContext) (interface{}, error) {
+			return contract.ListFlaggedProducts(ctx)
+		},
+		"SearchProducts": func(ctx *MockTransactionContext) (interface{}, error) {
+			return contract.SearchProducts(ctx, "TechCorp", "", "")
+		},
+	}
+
+	for name, query := range queries {
+		t.Run(name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			expectNoConfig(stub)
+			stub.On("GetStateByRange", "", "").Return(newProductIterator(t), nil).Maybe()
+			stub.On("GetQueryResult", mock.Anything).Return(newProductIterator(t), nil).Maybe()
+
+			result, err := query(ctx)
+			require.NoError(t, err)
+			resultBytes, err := json.Marshal(result)
+			require.NoError(t, err)
+			require.Equal(t, "[]", string(resultBytes))
+		})
+	}
+}
+
+func TestTransferOwnershipMovesOwnerOrg(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetStateValidationParameter", "prod-1", mock.Anything).Return(nil)
+	stub.On("SetEvent", OwnershipTransferredEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	require.NoError(t, contract.TransferOwnership(ctx, "prod-1", "GlobalDistributors", "Org2MSP"))
+
+	product := putProduct(t, stub, "prod-1")
+	require.Equal(t, "GlobalDistributors", product.CurrentOwner)
+	require.Equal(t, "Org2MSP", product.CurrentOwnerOrg)
+
+	var payload OwnershipTransferredPayload
+	for _, call := range stub.Calls {
+		if call.Method == "SetEvent" {
+			require.NoError(t, json.Unmarshal(call.Arguments.Get(1).([]byte), &payload))
+		}
+	}
+	require.Equal(t, "Org2MSP", payload.NewOwnerOrg)
+
+	// The seller's organization has lost control of the product
+	err := contract.assertOwnerOrg(ctx, product)
+	require.ErrorIs(t, err, ErrPermissionDenied)
+}
+
+func TestTransferOwnershipRequiresOwnerOrg(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	err := contract.TransferOwnership(ctx, "prod-1", "GlobalDistributors", " ")
+	require.ErrorContains(t, err, "organization (MSP ID) must not be empty")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestModifyProductOwnerKeepsOwnerOrg(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	changed, err := contract.ModifyProduct(ctx, "prod-1", "", "TechCorp Logistics", "", "")
+	require.NoError(t, err)
+	require.True(t, changed)
+
+	product := putProduct(t, stub, "prod-1")
+	require.Equal(t, "TechCorp Logistics", product.CurrentOwner)
+	require.Equal(t, testMSPID, product.CurrentOwnerOrg)
+}
+
+func TestDeleteProductPermissions(t *testing.T) {
+	tests := []struct {
+		name    string
+		mspID   string
+		admin   bool
+		allowed bool
+	}{
+		{"owning organization", "Org2MSP", false, true},
+		{"admin", adminMSPID, true, true},
+		{"admin organization client without the admin attribute", adminMSPID, false, false},
+		{"other organization", "Org3MSP", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			setIdentity(ctx, tt.mspID, "", tt.admin)
+			stub.On("GetState", "prod-1").Return(storedProductOwnedBy(t, "prod-1", "Org2MSP"), nil)
+			stub.On("GetStateByPartialCompositeKey", mock.Anything, mock.Anything).Return(newProductIterator(t), nil).Maybe()
+			stub.On("DelState", mock.Anything).Return(nil).Maybe()
+			stub.On("SetEvent", ProductDeletedEvent, mock.Anything).Return(nil).Maybe()
+
+			contract := new(SupplyChainSmartContract)
+			err := contract.DeleteProduct(ctx, "prod-1")
+			if tt.allowed {
+				require.NoError(t, err)
+				stub.AssertCalled(t, "DelState", "prod-1")
+			} else {
+				require.ErrorIs(t, err, ErrPermissionDenied)
+				stub.AssertNotCalled(t, "DelState", mock.Anything)
+			}
+		})
+	}
+}
+
+func TestTransferOwnershipSetsEndorsementPolicyForNewOrg(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetStateValidationParameter", "prod-1", mock.Anything).Return(nil)
+	stub.On("SetEvent", OwnershipTransferredEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	require.NoError(t, contract.TransferOwnership(ctx, "prod-1", "GlobalDistributors", "Org2MSP"))
+
+	var policy []byte
+	for _, call := range stub.Calls {
+		if call.Method == "SetStateValidationParameter" {
+			policy = call.Arguments.Get(1).([]byte)
+		}
+	}
+	require.NotEmpty(t, policy)
+	endorsementPolicy, err := statebased.NewStateEP(policy)
+	require.NoError(t, err)
+	require.Equal(t, []string{"Org2MSP"}, endorsementPolicy.ListOrgs())
+}
+
+func TestModifyProductRejectsOwnerChangeWhileTransferPending(t *testing.T) {
+	var product ProductEntity
+	require.NoError(t, json.Unmarshal(storedProduct(t, "prod-1"), &product))
+	product.PendingOwner = "GlobalDistributors"
+	productBytes, err := json.Marshal(product)
+	require.NoError(t, err)
+
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-1").Return(productBytes, nil)
+
+	contract := new(SupplyChainSmartContract)
+	_, err = contract.ModifyProduct(ctx, "prod-1", "", "RetailHub", "", "")
+	require.ErrorContains(t, err, "product prod-1 has a pending transfer to GlobalDistributors")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestSwapOwnershipRequiresProposal(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("GetState", "prod-2").Return(storedProductOwnedBy(t, "prod-2", "Org2MSP"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	err := contract.SwapOwnership(ctx, "prod-1", "prod-2")
+	require.ErrorContains(t, err, "product prod-1 has no pending swap with prod-2")
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestSwapOwnershipRequiresCounterpartOwner(t *testing.T) {
+	var product ProductEntity
+	require.NoError(t, json.Unmarshal(storedProduct(t, "prod-1"), &product))
+	product.PendingSwapWith = "prod-2"
+	productBytes, err := json.Marshal(product)
+	require.NoError(t, err)
+
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(productBytes, nil)
+	stub.On("GetState", "prod-2").Return(storedProductOwnedBy(t, "prod-2", "Org2MSP"), nil)
+
+	contract := new(SupplyChainSmartContract)
+	err = contract.SwapOwnership(ctx, "prod-1", "prod-2")
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+}
+
+func TestProposeAndAcceptSwap(t *testing.T) {
+	ctx, stub := newMockContext()
+	expectNoConfig(stub)
+	proposal := stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("GetState", "prod-2").Return(storedProductOwnedBy(t, "prod-2", "Org2MSP"), nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetStateValidationParameter", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetEvent", OwnershipSwappedEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	require.NoError(t, contract.ProposeSwap(ctx, "prod-1", "prod-2"))
+	proposed := putProduct(t, stub, "prod-1")
+	require.Equal(t, "prod-2", proposed.PendingSwapWith)
+
+	proposedBytes, err := json.Marshal(proposed)
+	require.NoError(t, err)
+	proposal.Unset()
+	stub.Calls = nil
+	stub.On("GetState", "prod-1").Return(proposedBytes, nil)
+	useIdentity(ctx, "Org2MSP")
+	require.NoError(t, contract.SwapOwnership(ctx, "prod-1", "prod-2"))
+
+	swapped := putProduct(t, stub, "prod-1")
+	require.Equal(t, "Org2MSP", swapped.CurrentOwnerOrg)
+	require.Empty(t, swapped.PendingSwapWith)
+	require.Equal(t, testMSPID, putProduct(t, stub, "prod-2").CurrentOwnerOrg)
+}
+
+func TestDeleteProductRemovesReadingsAndStatusLog(t *testing.T) {
+	readingKey, err := shim.CreateCompositeKey(sensorReadingIndex, []string{"prod-1", "2024-01-02T00:00:00Z"})
+	require.NoError(t, err)
+	statusLogKey, err := shim.CreateCompositeKey(statusLogIndex, []string{"prod-1", testTxID})
+	require.NoError(t, err)
+
+	ctx, stub := newMockContext()
+	stub.On("GetState", "prod-1").Return(storedProduct(t, "prod-1"), nil)
+	stub.On("GetStateByPartialCompositeKey", sensorReadingIndex, []string{"prod-1"}).
+		Return(&MockStateQueryIterator{results: []*queryresult.KV{{Key: readingKey}}}, nil)
+	stub.On("GetStateByPartialCompositeKey", statusLogIndex, []string{"prod-1"}).
+		Return(&MockStateQueryIterator{results: []*queryresult.KV{{Key: statusLogKey}}}, nil)
+	stub.On("DelState", mock.Anything).Return(nil)
+	stub.On("SetEvent", ProductDeletedEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	require.NoError(t, contract.DeleteProduct(ctx, "prod-1"))
+	stub.AssertCalled(t, "DelState", readingKey)
+	stub.AssertCalled(t, "DelState", statusLogKey)
+	stub.AssertCalled(t, "DelState", "prod-1")
+}
+
+func TestRegisterProductIdempotentIgnoresDeletedProduct(t *testing.T) {
+	idempotencyKey, err := shim.CreateCompositeKey(idempotencyIndex, []string{"retry-1"})
+	require.NoError(t, err)
+
+	ctx, stub := newMockContext()
+	stub.On("GetState", idempotencyKey).Return([]byte("prod-old"), nil)
+	expectNoConfig(stub)
+	stub.On("GetState", "prod-old").Return(nil, nil)
+	stub.On("GetState", "prod-1").Return(nil, nil)
+	stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+	stub.On("SetEvent", ProductRegisteredEvent, mock.Anything).Return(nil)
+
+	contract := new(SupplyChainSmartContract)
+	product, err := contract.RegisterProductIdempotent(ctx, "retry-1", "prod-1", "Laptop", "TechCorp", "", "electronics", 1, "")
+	require.NoError(t, err)
+	require.Equal(t, "prod-1", product.ProductID)
+	stub.AssertCalled(t, "PutState", idempotencyKey, []byte("prod-1"))
+}
+
+func TestRecomputeStatusQuantity(t *testing.T) {
+	tests := []struct {
+		name    string
+		stored  string
+		derived string
+	}{
+		{"zero quantity is depleted", `{"product_id":"prod-1","product_status":"Delivered","current_owner_org":"Org1MSP","quantity":0}`, StatusDepleted},
+		{"legacy record without quantity keeps its status", `{"product_id":"prod-1","product_status":"Delivered","current_owner_org":"Org1MSP"}`, StatusDelivered},
+		{"recalled without a recall date stays recalled", `{"product_id":"prod-1","product_status":"Recalled","current_owner_org":"Org1MSP","quantity":0,"expiry_date":"2023-01-01T00:00:00Z"}`, StatusRecalled},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			expectNoConfig(stub)
+			stub.On("GetState", "prod-1").Return([]byte(tt.stored), nil)
+			stub.On("PutState", mock.Anything, mock.Anything).Return(nil).Maybe()
+
+			contract := new(SupplyChainSmartContract)
+			status, err := contract.RecomputeStatus(ctx, "prod-1")
+			require.NoError(t, err)
+			require.Equal(t, tt.derived, status)
+		})
+	}
+}
+
+func TestAcceptTransferRequiresProposedOrg(t *testing.T) {
+	var product ProductEntity
+	require.NoError(t, json.Unmarshal(storedProduct(t, "prod-1"), &product))
+	product.PendingOwner = "GlobalDistributors"
+	product.PendingOwnerOrg = "Org2MSP"
+	productBytes, err := json.Marshal(product)
+	require.NoError(t, err)
+
+	t.Run("other organization with a matching owner attribute", func(t *testing.T) {
+		ctx, stub := newMockContext()
+		useOwnerIdentity(ctx, "Org3MSP", "GlobalDistributors")
+		stub.On("GetState", "prod-1").Return(productBytes, nil)
+
+		contract := new(SupplyChainSmartContract)
+		require.ErrorIs(t, contract.AcceptTransfer(ctx, "prod-1"), ErrPermissionDenied)
+		stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+	})
+
+	t.Run("proposed organization", func(t *testing.T) {
+		ctx, stub := newMockContext()
+		useOwnerIdentity(ctx, "Org2MSP", "GlobalDistributors")
+		stub.On("GetState", "prod-1").Return(productBytes, nil)
+		stub.On("PutState", mock.Anything, mock.Anything).Return(nil)
+		stub.On("SetStateValidationParameter", "prod-1", mock.Anything).Return(nil)
+		stub.On("SetEvent", OwnershipTransferredEvent, mock.Anything).Return(nil)
+
+		contract := new(SupplyChainSmartContract)
+		require.NoError(t, contract.AcceptTransfer(ctx, "prod-1"))
+		accepted := putProduct(t, stub, "prod-1")
+		require.Equal(t, "GlobalDistributors", accepted.CurrentOwner)
+		require.Equal(t, "Org2MSP", accepted.CurrentOwnerOrg)
+		require.Empty(t, accepted.PendingOwner)
+		require.Empty(t, accepted.PendingOwnerOrg)
+	})
+}
+
+func TestLegacyProductWithoutOwnerOrgRequiresAdmin(t *testing.T) {
+	tests := []struct {
+		name    string
+		mspID   string
+		admin   bool
+		allowed bool
+	}{
+		{"admin", adminMSPID, true, true},
+		{"admin organization client without the admin attribute", adminMSPID, false, false},
+		{"other organization", "Org2MSP", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, stub := newMockContext()
+			setIdentity(ctx, tt.mspID, "", tt.admin)
+			expectNoConfig(stub)
+			stub.On("GetState", "prod-1").Return(storedProductOwnedBy(t, "prod-1", ""), nil)
+			stub.On("PutState", mock.Anything, mock.Anything).Return(nil).Maybe()
+			stub.On("SetStateValidationParameter", "prod-1", mock.Anything).Return(nil).Maybe()
+			stub.On("SetEvent", OwnershipTransferredEvent, mock.Anything).Return(nil).Maybe()
+
+			contract := new(SupplyChainSmartContract)
+			err := contract.TransferOwnership(ctx, "prod-1", "GlobalDistributors", "Org2MSP")
+			if tt.allowed {
+				require.NoError(t, err)
+				require.Equal(t, "Org2MSP", putProduct(t, stub, "prod-1").CurrentOwnerOrg)
+			} else {
+				require.ErrorIs(t, err, ErrPermissionDenied)
+				stub.AssertNotCalled(t, "PutState", mock.Anything, mock.Anything)
+			}
+		})
+	}
+}
+
+func TestDeleteAllProductsRequiresAdminAttribute(t *testing.T) {
+	ctx, stub := newMockContext()
+
+	contract := new(SupplyChainSmartContract)
+	_, err := contract.DeleteAllProducts(ctx)
+	require.ErrorIs(t, err, ErrPermissionDenied)
+	stub.AssertNotCalled(t, "GetStateByRange", mock.Anything, mock.Anything)
+	stub.AssertNotCalled(t, "DelState", mock.Anything)
+
+	useAdminIdentity(ctx)
+	stub.On("GetStateByRange", "", "").Return(newProductIterator(t), nil)
+	deleted, err := contract.DeleteAllProducts(ctx)
+	require.NoError(t, err)
+	require.Zero(t, deleted)
+}
+
+func TestGetProductsByCreatorInRangeComparesParsedDates(t *testing.T) {
+	ctx, stub := newMockContext()
+	stub.On("GetQueryResult", `{"selector":{"created_by":"`+testClientID+`"}}`).Return(newProductIterator(t,
+		&ProductEntity{ProductID: "utc", CreatedDate: "2024-01-10T12:00:00Z"},
+		&ProductEntity{ProductID: "offset", CreatedDate: "2024-01-11T01:00:00+05:00"},
+		&ProductEntity{ProductID: "date-only", CreatedDate: "2024-01-12"},
+		&ProductEntity{ProductID: "offset-outside", CreatedDate: "2024-01-10T03:00:00+05:00"},
+		&ProductEntity{ProductID: "unparseable", CreatedDate: "yesterday"},
+	), nil)
+
+	contract := new(SupplyChainSmartContract)
+	products, err := contract.GetProductsByCreatorInRange(ctx, testClientID, "2024-01-10T00:00:00Z", "2024-01-12T23:59:59Z")
+	require.NoError(t, err)
+
+	var ids []string
+	for _, product := range products {
+		ids = append(ids, product.ProductID)
+	}
+	require.Equal(t, []string{"utc", "offset", "date-only"}, ids)
+}
+
+func TestTransactionTimestampIsUTC(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	ctx, _ := newMockContext()
+	contract := new(SupplyChainSmartContract)
+	timestamp, err := contract.fetchTransactionTimestamp(ctx)
+	require.NoError(t, err)
+	require.Equal(t, "2024-01-15T10:00:00Z", timestamp)
+}
+
+func TestListColdChainBreachesUsesBreachingReadingsOnly(t *testing.T) {
+	tests := []struct {
+		name          string
+		threshold     float64
+		wantMax       float64
+		wantTimestamp string
+		wantBreaching int
+	}{
+		{"in-range readings after the breach are ignored", 10, 11, "2024-01-02T00:00:00Z", 1},
+		{"threshold raised above every reading", 12, 0, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			product := &ProductEntity{ProductID: "prod-1", ColdChain: &ColdChainSettings{MaxTemperature: tt.threshold}, ColdChainBreached: true}
+			readings := &MockStateQueryIterator{}
+			for _, reading := range []SensorReading{
+				{ProductID: "prod-1", Timestamp: "2024-01-01T00:00:00Z", Temperature: 4},
+				{ProductID: "prod-1", Timestamp: "2024-01-02T00:00:00Z", Temperature: 11},
+				{ProductID: "prod-1", Timestamp: "2024-01-03T00:00:00Z", Temperature: 9.5},
+			} {
+				readingBytes, err := json.Marshal(reading)
+				require.NoError(t, err)
+				readings.results = append(readings.results, &queryresult.KV{Key: reading.Timestamp, Value: readingBytes})
+			}
+
+			ctx, stub := newMockContext()
+			stub.On("GetQueryResult", `{"selector":{"cold_chain_breached":true}}`).Return(newProductIterator(t, product), nil)
+			stub.On("GetStateByPartialCompositeKey", sensorReadingIndex, []string{"prod-1"}).Return(readings, nil)
+
+			contract := new(SupplyChainSmartContract)
+			breaches, err := contract.ListColdChainBreaches(ctx)
+			require.NoError(t, err)
+			require.Len(t, breaches, 1)
+			require.Equal(t, tt.wantMax, breaches[0].MaxTemperature)
+			require.Equal(t, tt.wantTimestamp, breaches[0].WorstBreachTimestamp)
+			require.Len(t, breaches[0].BreachingReadings, tt.wantBreaching)
+		})
+	}
+}
+
+func TestGetProductHistory(t *testing.T) {
+	local := time.Local
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+	defer func() { time.Local = local }()
+
+	ctx, stub := newMockContext()
+	stub.On("GetHistoryForKey", "prod-1").Return(&MockHistoryQueryIterator{results: []*queryresult.KeyModification{
+		{TxId: "tx-1", Value: storedProduct(t, "prod-1"), Timestamp: timestamppb.New(testTxTime)},
+	}}, nil)
+	stub.On("GetHistoryForKey", "prod-2").Return(&MockHistoryQueryIterator{}, nil)
+
+	contract := new(SupplyChainSmartContract)
+	history, err := contract.GetProductHistory(ctx, "prod-1")
+	require.NoError(t, err)
+	require.Len(t, history, 1)
+	require.Equal(t, "2024-01-15T10:00:00Z", history[0].Timestamp)
+
+	_, err = contract.GetProductHistory(ctx, "prod-2")
+	require.ErrorIs(t, err, ErrProductNotFound)
+}
+
+func TestGetProductLineageTruncation(t *testing.T) {
+	tests := []struct {
+		name        string
+		generations int
+		truncated   bool
+	}{
+		{"chain ending exactly at the cap", maxLineageDepth, false},
+		{"chain longer than the cap", maxLineageDepth + 1, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			products := []*ProductEntity{{ProductID: "gen-00"}}
+			for i := 1; i <= tt.generations; i++ {
+				products = append(products, &ProductEntity{ProductID: fmt.Sprintf("gen-%02d", i), ParentID: fmt.Sprintf("gen-%02d", i-1)})
+			}
+			rootBytes, err := json.Marshal(products[0])
+			require.NoError(t, err)
+
+			ctx, stub := newMockContext()
+			stub.On("GetState", "gen-00").Return(rootBytes, nil)
+			stub.On("GetStateByRange", "", "").Return(newProductIterator(t, products...), nil)
+
+			contract := new(SupplyChainSmartContract)
+			lineage, err := contract.GetProductLineage(ctx, "gen-00")
+			require.NoError(t, err)
+			require.Equal(t, tt.truncated, lineage.Truncated)
+			require.Len(t, lineage.Nodes, maxLineageDepth+1)
+		})
+	}
+}